@@ -0,0 +1,104 @@
+package valfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// readArchiveEntry reads the contents of the file entry inside the
+// tar, tar.gz or zip archive at archivePath.
+func readArchiveEntry(archivePath, entry string) ([]byte, error) {
+	b, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	entry = path.Clean(entry)
+
+	switch name := strings.ToLower(archivePath); {
+	case strings.HasSuffix(name, ".zip"):
+		return readZipEntry(b, entry)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip: %w", err)
+		}
+		return readTarEntry(r, entry)
+	case strings.HasSuffix(name, ".tar"):
+		return readTarEntry(bytes.NewReader(b), entry)
+	}
+	return nil, fmt.Errorf("unsupported archive type: %q", path.Base(archivePath))
+}
+
+func readZipEntry(b []byte, entry string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+	for _, f := range r.File {
+		if path.Clean(f.Name) != entry || f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("entry %q not found in archive", entry)
+}
+
+func readTarEntry(r io.Reader, entry string) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("entry %q not found in archive", entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		if path.Clean(h.Name) == entry && h.Typeflag == tar.TypeReg {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// isGzip returns true if the input file name, or the path of the input URL,
+// has the extension .gz of a gzip-compressed file.
+func isGzip(name string) bool {
+	if isURL(name) {
+		name = urlPath(name)
+	}
+	return strings.EqualFold(path.Ext(name), ".gz")
+}
+
+// gunzipInput decompresses the gzip-compressed input b.
+// Inputs decompressing to more than p.MaxInputSize
+// aren't decompressed entirely.
+func gunzipInput(p Params, b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip: %w", err)
+	}
+	var r io.Reader = zr
+	if p.MaxInputSize > 0 {
+		r = io.LimitReader(r, p.MaxInputSize+1)
+	}
+	d, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip: %w", err)
+	}
+	if err := checkInputSize(p, int64(len(d))); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
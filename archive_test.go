@@ -0,0 +1,118 @@
+package valfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGunzipInput(t *testing.T) {
+	b, err := gunzipInput(Params{}, []byte(makeGzip(`{"foo":"bar"}`)))
+	require.NoError(t, err)
+	require.Equal(t, `{"foo":"bar"}`, string(b))
+
+	_, err = gunzipInput(Params{MaxInputSize: 8}, []byte(makeGzip(`{"foo":"bar"}`)))
+	require.EqualError(t, err, "input size 9 bytes exceeds the maximum "+
+		"of 8 bytes, see -max-input-size")
+
+	_, err = gunzipInput(Params{}, []byte(`{"foo":"bar"}`))
+	require.EqualError(t, err, "reading gzip: gzip: invalid header")
+
+	truncated := makeGzip(`{"foo":"bar"}`)
+	_, err = gunzipInput(Params{}, []byte(truncated[:len(truncated)-4]))
+	require.EqualError(t, err, "reading gzip: unexpected EOF")
+}
+
+func TestReadArchiveEntry(t *testing.T) {
+	files := map[string]string{
+		"config.yaml":      "foo: bar\n",
+		"conf/prod.json":   `{"foo":"bar"}`,
+		"conf/ignore.toml": `foo="bar"`,
+	}
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"bundle.tar.gz": makeTarGz(files),
+		"bundle.zip":    makeZip(files),
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0o644))
+
+		b, err := readArchiveEntry(p, "conf/prod.json")
+		require.NoError(t, err, name)
+		require.Equal(t, `{"foo":"bar"}`, string(b), name)
+
+		b, err = readArchiveEntry(p, "./config.yaml")
+		require.NoError(t, err, name)
+		require.Equal(t, "foo: bar\n", string(b), name)
+
+		_, err = readArchiveEntry(p, "missing.yaml")
+		require.EqualError(t, err, `entry "missing.yaml" not found in archive`, name)
+	}
+
+	p := filepath.Join(dir, "bundle.rar")
+	require.NoError(t, os.WriteFile(p, nil, 0o644))
+	_, err := readArchiveEntry(p, "config.yaml")
+	require.EqualError(t, err, `unsupported archive type: "bundle.rar"`)
+}
+
+// makeTarGz returns a gzip-compressed tar archive of files.
+func makeTarGz(files map[string]string) string {
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for _, name := range sortedKeys(files) {
+		must(tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(files[name])),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(files[name]))
+		must(err)
+	}
+	must(tw.Close())
+	must(gw.Close())
+	return b.String()
+}
+
+// makeGzip returns the gzip-compressed contents.
+func makeGzip(contents string) string {
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	_, err := gw.Write([]byte(contents))
+	must(err)
+	must(gw.Close())
+	return b.String()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 checksum of contents.
+func sha256Hex(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+// makeZip returns a zip archive of files.
+func makeZip(files map[string]string) string {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range sortedKeys(files) {
+		w, err := zw.Create(name)
+		must(err)
+		_, err = w.Write([]byte(files[name]))
+		must(err)
+	}
+	must(zw.Close())
+	return b.String()
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,11 @@
+// Command valfile validates config files and environment variables
+// against Go struct types.
+package main
+
+import (
+	"os"
+
+	"github.com/romshark/valfile"
+)
+
+func main() { os.Exit(valfile.RunCLI(os.Args)) }
@@ -0,0 +1,135 @@
+package valfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFileName is the conventional name of a valfile config file.
+const DefaultConfigFileName = ".valfile.yaml"
+
+// Config is the contents of a valfile config file.
+type Config struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// Target declares a set of input files to be validated against a Go type.
+type Target struct {
+	Package string   `yaml:"package"`
+	Type    string   `yaml:"type"`
+	Files   []string `yaml:"files"`
+	Env     bool     `yaml:"env"`
+}
+
+// loadConfig reads the config file at path.
+// Relative package and file paths are resolved relative to
+// the directory of the config file.
+func loadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	var c Config
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+	if err := d.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		switch {
+		case t.Type == "":
+			return Config{}, fmt.Errorf("target %d: missing type name", i)
+		case !t.Env && len(t.Files) < 1:
+			return Config{}, fmt.Errorf("target %d: missing input files", i)
+		case t.Env && len(t.Files) > 0:
+			return Config{}, fmt.Errorf(
+				"target %d: env and files are mutually exclusive", i,
+			)
+		}
+		if t.Package == "" {
+			t.Package = "."
+		}
+		t.Package = resolvePath(dir, t.Package)
+		for j := range t.Files {
+			t.Files[j] = resolvePath(dir, t.Files[j])
+		}
+	}
+	return c, nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// checkTargetFilesExist returns a single error listing all input files
+// declared by targets that don't exist.
+func checkTargetFilesExist(targets []Target) error {
+	var missing []string
+	for _, t := range targets {
+		for _, f := range t.Files {
+			if _, err := os.Stat(f); errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, f)
+			}
+		}
+	}
+	if missing == nil {
+		return nil
+	}
+	return fmt.Errorf("missing input files: %s", strings.Join(missing, ", "))
+}
+
+// findUncoveredFiles returns the paths of all files in the directory of
+// configFile and its subdirectories that have a supported input format but
+// aren't an input file of any of the targets. Hidden directories, vendor
+// directories and the config file itself are skipped.
+func findUncoveredFiles(configFile string, targets []Target) ([]string, error) {
+	abs := func(path string) string {
+		if p, err := filepath.Abs(path); err == nil {
+			return p
+		}
+		return filepath.Clean(path)
+	}
+	covered := map[string]bool{abs(configFile): true}
+	for _, t := range targets {
+		for _, f := range t.Files {
+			covered[abs(f)] = true
+		}
+	}
+	var uncovered []string
+	root := filepath.Dir(configFile)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, err := getFileFormat(path); err != nil || covered[abs(path)] {
+			return nil
+		}
+		uncovered = append(uncovered, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching for uncovered files: %w", err)
+	}
+	return uncovered, nil
+}
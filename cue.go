@@ -0,0 +1,25 @@
+package valfile
+
+import (
+	"errors"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+)
+
+// evaluateCUE evaluates the CUE file name with the contents data
+// and returns the resulting value as JSON, which must be concrete.
+// All evaluation errors are combined into a single error.
+func evaluateCUE(name string, data []byte) (string, error) {
+	v := cuecontext.New().CompileBytes(data, cue.Filename(name))
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return "", errors.New(strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return "", errors.New(strings.TrimSpace(cueerrors.Details(err, nil)))
+	}
+	return string(b), nil
+}
@@ -0,0 +1,23 @@
+package valfile
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/philandstuff/dhall-golang/v6"
+)
+
+// evaluateDhall type-checks and evaluates the Dhall file name with
+// the contents data and returns the resulting value as JSON,
+// like dhall-to-json. Imports are resolved relative to name.
+func evaluateDhall(name string, data []byte) (string, error) {
+	var v any
+	if err := dhall.UnmarshalReader(name, bytes.NewReader(data), &v); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
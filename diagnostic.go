@@ -0,0 +1,187 @@
+package valfile
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// Diagnostic codes.
+const (
+	CodeError   = "ERROR"   // Generic error.
+	CodeTag     = "TAG"     // Invalid or missing marshaling tag.
+	CodeInvalid = "INVALID" // Input doesn't match the type.
+	CodeType    = "TYPE"    // Type can't be resolved.
+
+	// Parameters aren't supported for the input.
+	CodeUsage = "USAGE"
+
+	// The Go toolchain is missing or failed to run the generated program.
+	CodeToolchain = "TOOLCHAIN"
+
+	// File isn't validated by any target of the config file.
+	CodeUncovered = "UNCOVERED"
+
+	// Optional field isn't set and falls back to its default value.
+	CodeDefault = "DEFAULT"
+
+	// Field isn't set by any input of its type.
+	CodeUnused = "UNUSED"
+
+	// The message is the effective input, the decoded value marshaled again.
+	CodeEffective = "EFFECTIVE"
+
+	// The message is the path of the temporary directory of a generated
+	// program kept due to Params.KeepTemp.
+	CodeTempDir = "TEMP_DIR"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity int8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return "error"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is an error with structured details.
+type Diagnostic struct {
+	Severity Severity
+
+	// File is the path of the file the diagnostic refers to, if any.
+	File string
+
+	// Line and Column are 1-based, 0 if unknown.
+	Line, Column int
+
+	Code    string
+	Message string
+
+	// Type and Field are the name of the type and the path of the field
+	// within it, such as Server.Port, the diagnostic refers to, if known.
+	Type, Field string
+
+	// Defaulted is the unset field of diagnostics of code CodeDefault.
+	Defaulted *DefaultedField
+}
+
+// DefaultedField is an optional field that isn't set by the input
+// and falls back to its default value.
+type DefaultedField struct {
+	// Path is the path of the field, such as Config.Server.Port.
+	Path string `json:"path"`
+
+	// Key is the key name of the field in the input.
+	Key string `json:"key"`
+
+	// Default is the formatted default value, strings are quoted.
+	Default string `json:"default"`
+}
+
+func (d *Diagnostic) Error() string {
+	switch d.Severity {
+	case SeverityWarning:
+		return "warning: " + d.Message
+	case SeverityInfo:
+		return "info: " + d.Message
+	}
+	return d.Message
+}
+
+// severityOf returns the severity of err.
+// Errors that aren't diagnostics are of severity SeverityError.
+func severityOf(err error) Severity {
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return d.Severity
+	}
+	return SeverityError
+}
+
+// withoutInfo returns errs without diagnostics of severity SeverityInfo.
+func withoutInfo(errs []error) []error {
+	var filtered []error
+	for _, err := range errs {
+		if severityOf(err) != SeverityInfo {
+			filtered = append(filtered, err)
+		}
+	}
+	return filtered
+}
+
+// warningsAsErrors returns errs with diagnostics of severity SeverityWarning
+// replaced by copies of severity SeverityError.
+func warningsAsErrors(errs []error) []error {
+	if errs == nil {
+		return nil
+	}
+	converted := make([]error, len(errs))
+	for i, err := range errs {
+		converted[i] = err
+		if d, ok := err.(*Diagnostic); ok && d.Severity == SeverityWarning {
+			c := *d
+			c.Severity = SeverityError
+			converted[i] = &c
+		}
+	}
+	return converted
+}
+
+// asDiagnostic returns err as a diagnostic.
+// A *ToolchainError is reported with code CodeToolchain,
+// other errors that aren't diagnostics with code CodeError.
+func asDiagnostic(err error) *Diagnostic {
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return d
+	}
+	var te *ToolchainError
+	if errors.As(err, &te) {
+		return &Diagnostic{Code: CodeToolchain, Message: err.Error()}
+	}
+	return &Diagnostic{Code: CodeError, Message: err.Error()}
+}
+
+// withCode returns errs with the errors that aren't diagnostics
+// converted to diagnostics of the given code.
+// A *ToolchainError is kept as is, it's a diagnostic of code CodeToolchain.
+func withCode(code string, errs ...error) []error {
+	converted := make([]error, len(errs))
+	for i, err := range errs {
+		var d *Diagnostic
+		var te *ToolchainError
+		if errors.As(err, &d) || errors.As(err, &te) {
+			converted[i] = err
+			continue
+		}
+		converted[i] = &Diagnostic{Code: code, Message: err.Error()}
+	}
+	return converted
+}
+
+var regexLine = regexp.MustCompile(`\bline (\d+)\b`)
+
+// newInvalidInputDiagnostic creates a diagnostic for an error reported by the
+// generated program. The line number is extracted from the message if present.
+func newInvalidInputDiagnostic(msg string) *Diagnostic {
+	d := &Diagnostic{Code: CodeInvalid, Message: msg}
+	if m := regexLine.FindStringSubmatch(msg); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+	}
+	return d
+}
@@ -0,0 +1,951 @@
+package valfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/go-jsonnet"
+	"github.com/joho/godotenv"
+)
+
+// ErrMissingToolchain is returned if the go command isn't found in PATH.
+var ErrMissingToolchain = errors.New("the Go toolchain is required to validate " +
+	"inputs but the go command wasn't found in PATH, install Go from " +
+	"https://go.dev/dl and make sure its bin directory is in PATH")
+
+// ToolchainError is returned if the generated program fails to compile
+// or exits with a failure without reporting any error about the input,
+// as opposed to errors reported about the input.
+type ToolchainError struct {
+	// Op is the failed operation, such as "compiling generated program".
+	Op  string
+	Err error
+
+	// Stdout and Stderr are the captured output of the failed command.
+	Stdout, Stderr []byte
+
+	// Dir is the directory of the generated program if it's kept,
+	// see Params.KeepTemp.
+	Dir string
+}
+
+func (e *ToolchainError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Dir != "" {
+		fmt.Fprintf(&b, " in %s", e.Dir)
+	}
+	fmt.Fprintf(&b, ": %v\n", e.Err)
+	b.Write(bytes.TrimSpace(bytes.Join([][]byte{
+		bytes.TrimSpace(e.Stdout), bytes.TrimSpace(e.Stderr),
+	}, []byte("\n"))))
+	return b.String()
+}
+
+func (e *ToolchainError) Unwrap() error { return e.Err }
+
+// runToolchain runs cmd and returns a *ToolchainError of operation op
+// if it fails, along with its standard output.
+func runToolchain(op string, cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), &ToolchainError{
+			Op: op, Err: err, Stdout: stdout.Bytes(), Stderr: stderr.Bytes(),
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// Engine validates inputs against the type selected by its parameters.
+// The package is parsed, the types are resolved and the generated program
+// is rendered to a temporary module only once per input format,
+// subsequent validations of inputs of the same format only run the program
+// passing it the path of the input file. If Params.CacheDir is set,
+// the program is compiled once and cached, such that subsequent runs
+// of the same program don't compile it again.
+//
+// Engine is safe for concurrent use. Close must be called
+// to remove the temporary modules once the Engine is no longer used.
+type Engine struct {
+	params     Params
+	makeTmpDir func() string
+
+	lock    sync.Mutex
+	formats map[InputType]*engineFormat
+}
+
+// engineFormat is the state of an Engine for a single input format.
+type engineFormat struct {
+	tag   string
+	types resolvedTypes
+
+	// errs are the errors of type resolution and the tag check,
+	// which are reported for every input of this format.
+	errs []error
+
+	// dir is the directory of the generated program, which only contains
+	// the input directory if the program was found in the cache.
+	dir string
+
+	// bin is the path to the compiled program,
+	// which is in dir unless the program is cached.
+	bin string
+
+	// cleanup removes the workspace of the generated program.
+	cleanup func() error
+
+	// effectiveFormat is the format of the printed effective input.
+	effectiveFormat string
+
+	// keptTempDir is the temporary directory of the generated program
+	// kept due to Params.KeepTemp, empty once it's reported.
+	keptTempDir string
+}
+
+// reportKeptTempDir returns errs with a diagnostic of severity SeverityInfo
+// and code CodeTempDir of the kept temporary directory of f,
+// unless it's reported already.
+func (f *engineFormat) reportKeptTempDir(errs []error) []error {
+	if f.keptTempDir == "" {
+		return errs
+	}
+	errs = append(errs, &Diagnostic{
+		Severity: SeverityInfo,
+		Code:     CodeTempDir,
+		Message:  f.keptTempDir,
+	})
+	f.keptTempDir = ""
+	return errs
+}
+
+// NewEngine creates an Engine validating inputs against the type selected
+// by p. Temporary modules are created in the directory returned by makeTmpDir.
+func NewEngine(p Params, makeTmpDir func() string) *Engine {
+	return &Engine{
+		params:     p,
+		makeTmpDir: makeTmpDir,
+		formats:    map[InputType]*engineFormat{},
+	}
+}
+
+// Close removes all temporary modules of e.
+func (e *Engine) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var errs []error
+	for _, t := range sortedKeys(e.formats) {
+		if f := e.formats[t]; f.cleanup != nil {
+			errs = append(errs, f.cleanup())
+		}
+		delete(e.formats, t)
+	}
+	return errors.Join(errs...)
+}
+
+// Validate validates data of the given format and returns all errors
+// and warnings. Data of format InputTypeENV is expected in dotenv syntax.
+// Unless Params.BaseDir is set, relative paths of fields tagged
+// valfile:"file" or valfile:"dir" are resolved against the working directory.
+func (e *Engine) Validate(format InputType, data []byte) []error {
+	return e.validateFile(format, "input"+formatExtension(format), data)
+}
+
+// CompileCheck renders and compiles the generated program validating
+// inputs of the given format without running it, which reports type
+// resolution, marshaling tag and compilation errors. With Params.KeepTemp,
+// the kept directory of a program compiled successfully is reported
+// as a diagnostic of code CodeTempDir.
+func (e *Engine) CompileCheck(format InputType) []error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// The program is compiled when the format is prepared.
+	f, errs := e.format(format)
+	if errs != nil {
+		return errs
+	}
+	return f.reportKeptTempDir(nil)
+}
+
+// goCommand returns the go command compiling the generated programs.
+func (e *Engine) goCommand() string {
+	if e.params.GoCommand != "" {
+		return e.params.GoCommand
+	}
+	return "go"
+}
+
+// lookGoCommand returns an error if the go command compiling
+// the generated programs isn't found.
+func (e *Engine) lookGoCommand() error {
+	_, err := exec.LookPath(e.goCommand())
+	switch {
+	case err == nil:
+		return nil
+	case e.params.GoCommand == "":
+		return ErrMissingToolchain
+	}
+	return &Diagnostic{Code: CodeToolchain, Message: fmt.Sprintf(
+		"go command %s not found: %v", e.params.GoCommand, err,
+	)}
+}
+
+// goEnv are the environment variables configuring the go command
+// that goCommandEnv forwards to the go command compiling the generated
+// programs, such as the proxy settings of locked-down environments.
+var goEnv = []string{"GOPROXY", "GOFLAGS", "GOCACHE"}
+
+// goCommandEnv returns the environment of the go command compiling
+// the generated programs given the environment environ of the caller.
+// The variables in goEnv are forwarded explicitly and workspaces are
+// disabled since the generated module must build in vendor mode
+// even if it's set up in a directory of a workspace.
+func goCommandEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)+1)
+	forwarded := map[string]string{}
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		switch {
+		case slices.Contains(goEnv, k):
+			forwarded[k] = v
+		case k != "GOWORK":
+			env = append(env, kv)
+		}
+	}
+	for _, k := range goEnv {
+		if v, ok := forwarded[k]; ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return append(env, "GOWORK=off")
+}
+
+// buildProgram compiles the program in dir to the executable out
+// using the go command goCmd. The dependencies are vendored,
+// which doesn't require network access, and paths are trimmed such that
+// the vendored packages compiled in other directories are reused
+// from the build cache.
+func buildProgram(goCmd, dir, out string) error {
+	cmd := exec.Command(goCmd, "build", "-mod=vendor", "-trimpath", "-o", out, ".")
+	cmd.Dir = dir
+	cmd.Env = goCommandEnv(os.Environ())
+	_, err := runToolchain("compiling generated program", cmd)
+	return err
+}
+
+// validateFile validates the contents data of the file with the given name.
+func (e *Engine) validateFile(format InputType, name string, data []byte) []error {
+	empty := len(bytes.TrimSpace(data)) < 1
+	var input any
+	switch format {
+	case InputTypeENV, InputTypeDOTENV:
+		m, err := godotenv.Parse(bytes.NewReader(data))
+		if err != nil {
+			return []error{fmt.Errorf("parsing dotenv file: %w", err)}
+		}
+		input = m
+	case InputTypeENVRC:
+		m, err := parseEnvrc(data, e.params.EnvrcStrict)
+		if err != nil {
+			return []error{fmt.Errorf("parsing .envrc file: %w", err)}
+		}
+		input = m
+	case InputTypeJSONNET:
+		if empty {
+			// Decodes to the zero value like the other formats.
+			input = ""
+			break
+		}
+		vm := jsonnet.MakeVM()
+		for k, v := range e.params.JsonnetExtStr {
+			vm.ExtVar(k, v)
+		}
+		for k, v := range e.params.JsonnetExtCode {
+			vm.ExtCode(k, v)
+		}
+		vm.Importer(&jsonnet.FileImporter{JPaths: e.params.JsonnetPaths})
+		rendered, err := vm.EvaluateAnonymousSnippet(name, string(data))
+		if err != nil {
+			return []error{fmt.Errorf("evaluating Jsonnet: %w", err)}
+		}
+		input = rendered
+	case InputTypeCUE:
+		if empty {
+			input = ""
+			break
+		}
+		rendered, err := evaluateCUE(name, data)
+		if err != nil {
+			return []error{fmt.Errorf("evaluating CUE: %w", err)}
+		}
+		input = rendered
+	case InputTypeDHALL:
+		if empty {
+			input = ""
+			break
+		}
+		rendered, err := evaluateDhall(name, data)
+		if err != nil {
+			return []error{fmt.Errorf("evaluating Dhall: %w", err)}
+		}
+		input = rendered
+	default:
+		input = string(data)
+	}
+	return e.validateInput(format, name, input)
+}
+
+// validateInput validates input, which is a string for file formats
+// and a map[string]string for environment variables.
+// The input is written to a file in the module directory,
+// which is passed to the generated program.
+func (e *Engine) validateInput(format InputType, name string, input any) []error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	f, errs := e.format(format)
+	if errs != nil {
+		return errs
+	}
+
+	baseDir := e.params.BaseDir
+	switch {
+	case baseDir != "":
+	case isURL(name):
+		// Relative paths of fetched inputs are resolved
+		// against the working directory.
+		baseDir = "."
+	default:
+		baseDir = filepath.Dir(name)
+	}
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return []error{fmt.Errorf("resolving base directory: %w", err)}
+	}
+
+	var data []byte
+	switch input := input.(type) {
+	case string:
+		data = []byte(input)
+	default:
+		if data, err = json.Marshal(input); err != nil {
+			return []error{fmt.Errorf("encoding input: %w", err)}
+		}
+	}
+	// Every input is written to a directory of its own,
+	// such that runs sharing a persistent workspace don't interfere.
+	inputDir, err := os.MkdirTemp(filepath.Join(f.dir, "input"), "")
+	if err != nil {
+		return []error{fmt.Errorf("creating input directory: %w", err)}
+	}
+	defer os.RemoveAll(inputDir)
+	inputFile := filepath.Base(name)
+	if name == "" {
+		inputFile = "input" + formatExtension(format)
+	}
+	inputFile = filepath.Join(inputDir, inputFile)
+	if err := os.WriteFile(inputFile, data, 0o644); err != nil {
+		return []error{fmt.Errorf("writing %s: %w", inputFile, err)}
+	}
+
+	// The program reports problems in its output and only fails
+	// if it can't run, such as when it panics.
+	cmd := exec.Command(f.bin, inputFile, baseDir)
+	cmd.Dir = f.dir
+	output, err := runToolchain("running generated program", cmd)
+	if err != nil && !hasReportedErrors(output) {
+		return []error{e.keptDir(err, f.dir)}
+	}
+	return f.reportKeptTempDir(parseProgramOutput(output))
+}
+
+// format returns the state of e for the given input format,
+// preparing it on first use.
+func (e *Engine) format(t InputType) (*engineFormat, []error) {
+	if f, ok := e.formats[t]; ok {
+		return f, f.errs
+	}
+	f := &engineFormat{tag: t.MarshalingTag()}
+	if e.params.CacheDir == "" {
+		if err := e.lookGoCommand(); err != nil {
+			return nil, []error{err}
+		}
+	}
+	f.types, f.errs = e.prepare(t)
+	if f.errs == nil && e.params.PrintEffective {
+		var err error
+		if f.effectiveFormat, err = e.effectiveFormat(t); err != nil {
+			f.errs = withCode(CodeUsage, err)
+		}
+	}
+	if f.errs == nil {
+		var input any = ""
+		if t.MarshalingTag() == "env" {
+			input = map[string]string{}
+		}
+		if err := e.setupProgram(t, f, e.renderProgram(t, f, input)); err != nil {
+			return nil, []error{err}
+		}
+	}
+	e.formats[t] = f
+	return f, f.errs
+}
+
+// setupProgram sets up and compiles the generated program source validating
+// inputs of type t. Compilation errors are set as the errors of f.
+// If Params.CacheDir is set, the program is compiled to the cache
+// unless it's cached already, in which case only the input directory
+// is set up, which doesn't require the Go toolchain.
+// Cached programs that weren't used for unusedProgramAge are removed
+// when a new one is compiled.
+func (e *Engine) setupProgram(t InputType, f *engineFormat, source []byte) error {
+	if e.params.CacheDir != "" {
+		f.bin = e.cachedProgram(t, source)
+		if _, err := os.Stat(f.bin); err == nil {
+			touch(f.bin)
+			dir, err := os.MkdirTemp(e.makeTmpDir(), "valfile-*")
+			if err != nil {
+				return fmt.Errorf("creating temporary directory: %w", err)
+			}
+			if err := os.Mkdir(filepath.Join(dir, "input"), 0o755); err != nil {
+				os.RemoveAll(dir)
+				return fmt.Errorf("creating input directory: %w", err)
+			}
+			f.dir, f.cleanup = dir, func() error { return os.RemoveAll(dir) }
+			return nil
+		}
+		if err := e.lookGoCommand(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if f.dir, f.cleanup, err = e.setupWorkspace(t, source); err != nil {
+		return err
+	}
+	if e.params.KeepTemp && e.params.Workspace == "" {
+		f.keptTempDir = f.dir
+	}
+	if f.bin != "" {
+		if err = cacheProgram(e.goCommand(), f.dir, f.bin); err == nil {
+			pruneUnused(e.params.CacheDir, f.bin)
+		}
+	} else {
+		f.bin = filepath.Join(f.dir, executable("validator"))
+		err = buildProgram(e.goCommand(), f.dir, f.bin)
+	}
+	if err != nil {
+		f.errs = withCode(CodeToolchain, e.keptDir(err, f.dir))
+	}
+	return nil
+}
+
+// keptDir returns err with the directory dir of the generated program
+// if err is a *ToolchainError and the directory is kept.
+func (e *Engine) keptDir(err error, dir string) error {
+	var te *ToolchainError
+	if e.params.KeepTemp && errors.As(err, &te) {
+		te.Dir = dir
+	}
+	return err
+}
+
+// hasReportedErrors returns true if the output of the generated program
+// contains any error, which it reported before failing.
+func hasReportedErrors(output []byte) bool {
+	return bytes.HasPrefix(output, []byte(StdoutErrPrefix)) ||
+		bytes.Contains(output, []byte("\n"+StdoutErrPrefix))
+}
+
+// cachedProgram returns the path the compiled program source validating
+// inputs of type t is cached at, which is named after the hash of the source,
+// the module of the program and the go command compiling it, along with
+// the Go version valfile is built with.
+func (e *Engine) cachedProgram(t InputType, source []byte) string {
+	_, goMod, goSum, vendorArchive := formatProgram(t)
+	h := sha256.New()
+	for _, b := range [][]byte{
+		[]byte(runtime.Version()), []byte(e.goCommand()),
+		source, goMod, goSum, vendorArchive,
+	} {
+		fmt.Fprintf(h, "%d:", len(b))
+		h.Write(b)
+	}
+	name := "program-" + hex.EncodeToString(h.Sum(nil)[:16])
+	return filepath.Join(e.params.CacheDir, executable(name))
+}
+
+// unusedProgramAge is the duration after which programs in the cache
+// and in the workspace are removed if they weren't used.
+const unusedProgramAge = 30 * 24 * time.Hour
+
+// touch sets the modification time of the cached program or workspace
+// program directory at path to now, which marks it as used.
+// Errors are ignored, at worst the program is compiled again.
+func touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// pruneUnused removes the programs in dir that weren't used
+// for unusedProgramAge, except keep. Pruning is best-effort,
+// errors are ignored.
+func pruneUnused(dir, keep string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		if !strings.HasPrefix(entry.Name(), "program-") || p == keep {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < unusedProgramAge {
+			continue
+		}
+		_ = os.RemoveAll(p)
+	}
+}
+
+// executable returns name with the file extension of executables
+// of the operating system, if any.
+func executable(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// cacheProgram compiles the program in dir using the go command goCmd
+// and moves the executable to bin, which is replaced atomically such that
+// concurrent runs don't observe partially written executables.
+func cacheProgram(goCmd, dir, bin string) error {
+	if err := os.MkdirAll(filepath.Dir(bin), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	tmp := filepath.Join(filepath.Dir(bin), ".tmp-"+filepath.Base(dir)+"-"+filepath.Base(bin))
+	if err := buildProgram(goCmd, dir, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, bin); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("caching compiled program: %w", err)
+	}
+	return nil
+}
+
+// prepare resolves the types required for inputs of type t
+// and checks their marshaling tags.
+func (e *Engine) prepare(t InputType) (resolvedTypes, []error) {
+	if e.params.KindTypes != nil && t != InputTypeYAML {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-kind is only supported for YAML input"),
+		)
+	}
+	if e.params.SOPS && t != InputTypeYAML && t != InputTypeJSON {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-sops is only supported for YAML and JSON input"),
+		)
+	}
+	if e.params.StrictStrings && t != InputTypeYAML {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-strict-strings is only supported for YAML input"),
+		)
+	}
+	if e.params.FieldTypes != nil && t.MarshalingTag() == "env" {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-field isn't supported for environment variables"),
+		)
+	}
+	if e.params.EnvExact && t.MarshalingTag() != "env" {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-env-exact is only supported for environment variables"),
+		)
+	}
+	if e.params.EnvStrict && t.MarshalingTag() != "env" {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-env-strict is only supported for environment variables"),
+		)
+	}
+	if (e.params.JsonnetExtStr != nil || e.params.JsonnetExtCode != nil ||
+		e.params.JsonnetPaths != nil) && t != InputTypeJSONNET {
+		return resolvedTypes{}, withCode(CodeUsage, errors.New(
+			"-ext-str, -ext-code and -jpath are only supported for Jsonnet input",
+		))
+	}
+	if e.params.MultiDoc && t != InputTypeYAML {
+		return resolvedTypes{}, withCode(
+			CodeUsage, errors.New("-multi-doc is only supported for YAML input"),
+		)
+	}
+	if e.params.EnvCaseInsensitive && t.MarshalingTag() != "env" {
+		return resolvedTypes{}, withCode(CodeUsage, errors.New(
+			"-env-case-insensitive is only supported for environment variables",
+		))
+	}
+	fset, types, errs := e.resolve(t)
+	if errs != nil {
+		return resolvedTypes{}, errs
+	}
+	for _, k := range sortedKeys(types.Specs) {
+		errs = append(errs, checkRewriteOptions(fset, types.Specs[k], t)...)
+	}
+	if !e.params.NoTagCheck {
+		for _, k := range sortedKeys(types.Specs) {
+			s := types.Specs[k]
+			if err := checkMarshalingTags(
+				fset, s, t.MarshalingTag(), e.params.TagStyle, e.params.RequireTagOnAll,
+			); len(err) > 0 {
+				errs = append(errs, err...)
+			}
+		}
+	}
+	if e.params.ValidateTags || e.params.RequireValidate {
+		for _, k := range sortedKeys(types.Specs) {
+			errs = append(errs, checkValidateTags(
+				fset, types.Specs[k], e.params.RequireValidate,
+			)...)
+		}
+	}
+	if errs != nil {
+		return resolvedTypes{}, errs
+	}
+	return types, nil
+}
+
+// effectiveFormat returns the format the effective input of type t
+// is printed in, which is the input format unless Params.EffectiveFormat
+// is set. Formats that can't be marshaled are printed as JSON.
+func (e *Engine) effectiveFormat(t InputType) (string, error) {
+	if e.params.KindTypes != nil {
+		return "", errors.New("-print-effective isn't supported with -kind")
+	}
+	if e.params.MultiDoc {
+		return "", errors.New("-print-effective isn't supported with -multi-doc")
+	}
+	var native string
+	switch t {
+	case InputTypeJSON, InputTypeJSONNET, InputTypeCUE, InputTypeDHALL:
+		native = "json"
+	case InputTypeYAML:
+		native = "yaml"
+	case InputTypeTOML:
+		native = "toml"
+	}
+	switch requested := strings.ToLower(e.params.EffectiveFormat); requested {
+	case "":
+		if native == "" {
+			return "json", nil
+		}
+		return native, nil
+	case "json", native:
+		return requested, nil
+	case "yml":
+		if native == "yaml" {
+			return native, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"-effective-format %s is only supported for inputs of the same format",
+		e.params.EffectiveFormat,
+	)
+}
+
+// resolve parses the package and resolves the types required
+// for inputs of type t. Tags are rewritten as seen by the generated
+// program, unless t is zero.
+func (e *Engine) resolve(t InputType) (
+	*token.FileSet, resolvedTypes, []error,
+) {
+	p := e.params
+	fset := token.NewFileSet()
+
+	pkg, err := parsePackage(fset, p.PackageDir)
+	if err != nil {
+		return nil, resolvedTypes{}, withCode(CodeType, err)
+	}
+
+	expectMarshalingTag := t.MarshalingTag()
+	if p.TagFallback != nil && expectMarshalingTag != "" {
+		if err := applyTagFallback(pkg, expectMarshalingTag, p.TagFallback); err != nil {
+			return nil, resolvedTypes{}, withCode(CodeType, err)
+		}
+	}
+	if expectMarshalingTag == "env" {
+		if err := applyEnvPrefixes(fset, pkg); err != nil {
+			return nil, resolvedTypes{}, withCode(CodeType, err)
+		}
+	}
+
+	typeName := p.TypeName
+	if p.FieldTypes != nil {
+		typeName, err = addFieldsType(fset, pkg, p.PackageDir, t, p.FieldTypes)
+		if err != nil {
+			return nil, resolvedTypes{}, withCode(CodeUsage, err)
+		}
+	}
+	root, err := rootFieldType(fset, pkg, typeName)
+	if err != nil {
+		return nil, resolvedTypes{}, withCode(CodeType, err)
+	}
+	rootTypeNames := []string{root}
+	if p.KindTypes != nil {
+		rootTypeNames = sortedValues(p.KindTypes)
+	}
+
+	types, errs := resolveTypes(fset, pkg, rootTypeNames...)
+	if errs != nil {
+		return nil, resolvedTypes{}, withCode(CodeType, errs...)
+	}
+	types.Root = types.Roots[root]
+	if p.Enums {
+		types.Enums = findEnumValues(fset, pkg, types.Names)
+	}
+	return fset, types, nil
+}
+
+// setupWorkspace sets up the module of the generated program source
+// validating inputs of type t and returns the directory of the program,
+// which contains its main.go file and an empty input directory.
+// Unless Params.Workspace is set, the module is created in a temporary
+// directory, which cleanup removes unless Params.KeepTemp is set.
+// Otherwise, the module of each format is set up only once in the
+// persistent workspace and each program is written to a subdirectory
+// named after the hash of its source, which cleanup keeps.
+// Subdirectories of programs that weren't used for unusedProgramAge
+// are removed when a new one is written.
+func (e *Engine) setupWorkspace(t InputType, source []byte) (
+	dir string, cleanup func() error, err error,
+) {
+	if e.params.Workspace != "" {
+		module := filepath.Join(e.params.Workspace, t.MarshalingTag())
+		if err := ensureModule(module, t); err != nil {
+			return "", nil, err
+		}
+		sum := sha256.Sum256(source)
+		dir = filepath.Join(module, "program-"+hex.EncodeToString(sum[:8]))
+		_, statErr := os.Stat(dir)
+		if err := writeProgram(dir, source); err != nil {
+			return "", nil, err
+		}
+		touch(dir)
+		if statErr != nil {
+			pruneUnused(module, dir)
+		}
+		return dir, func() error { return nil }, nil
+	}
+
+	dir, err = os.MkdirTemp(e.makeTmpDir(), "valfile-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	cleanup = func() error { return os.RemoveAll(dir) }
+	if e.params.KeepTemp {
+		// Kept for inspecting the generated program.
+		cleanup = func() error { return nil }
+	}
+	if err = writeModule(dir, t); err == nil {
+		err = writeProgram(dir, source)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// writeModule writes the go.mod, go.sum and vendor directory
+// of the generated programs validating inputs of type t to dir.
+func writeModule(dir string, t InputType) error {
+	_, goMod, goSum, vendorArchive := formatProgram(t)
+	{
+		p := filepath.Join(dir, "go.mod")
+		if err := os.WriteFile(p, goMod, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", p, err)
+		}
+	}
+	{
+		p := filepath.Join(dir, "go.sum")
+		if err := os.WriteFile(p, goSum, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", p, err)
+		}
+	}
+	if err := unzipArchive(vendorArchive, dir); err != nil {
+		return fmt.Errorf("unzipping vendor directory: %w", err)
+	}
+	// The archives contain the vendored packages in GOPATH layout.
+	err := os.Rename(filepath.Join(dir, "src"), filepath.Join(dir, "vendor"))
+	if err != nil {
+		return fmt.Errorf("setting up vendor directory: %w", err)
+	}
+	return nil
+}
+
+// writeProgram writes the main.go file of the program source
+// and an empty input directory to dir, which is created if necessary.
+func writeProgram(dir string, source []byte) error {
+	if err := os.MkdirAll(filepath.Join(dir, "input"), 0o755); err != nil {
+		return fmt.Errorf("creating input directory: %w", err)
+	}
+	p := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(p, source, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", p, err)
+	}
+	return nil
+}
+
+// moduleStampFile is the file of a module in a persistent workspace
+// containing the hash of the contents the module was set up with.
+const moduleStampFile = "valfile.sum"
+
+// ensureModule sets up the module of the generated programs validating
+// inputs of type t in dir, unless it's already set up with the same contents.
+// Outdated modules are replaced including their programs.
+func ensureModule(dir string, t InputType) error {
+	_, goMod, goSum, vendorArchive := formatProgram(t)
+	h := sha256.New()
+	// The layout is part of the stamp to replace modules set up
+	// with the vendored packages outside the vendor directory.
+	for _, b := range [][]byte{goMod, goSum, vendorArchive, []byte("vendor")} {
+		h.Write(b)
+	}
+	stamp := hex.EncodeToString(h.Sum(nil))
+	isSetUp := func() bool {
+		b, err := os.ReadFile(filepath.Join(dir, moduleStampFile))
+		return err == nil && string(b) == stamp
+	}
+	if isSetUp() {
+		return nil
+	}
+
+	// The module is set up in a temporary directory that is renamed,
+	// such that concurrent runs never use a partially set up module.
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return fmt.Errorf("creating workspace: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := writeModule(tmp, t); err != nil {
+		return err
+	}
+	p := filepath.Join(tmp, moduleStampFile)
+	if err := os.WriteFile(p, []byte(stamp), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", p, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("removing outdated module: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil && !isSetUp() {
+		return fmt.Errorf("setting up workspace module: %w", err)
+	}
+	return nil
+}
+
+// renderProgram renders the generated program validating inputs of type t
+// with input embedded, which is used if the program is run without arguments.
+// The Engine embeds an empty input and passes the input file instead.
+func (e *Engine) renderProgram(t InputType, f *engineFormat, input any) []byte {
+	tmpl, _, _, _ := formatProgram(t)
+	var kindTypes map[string]string
+	if e.params.KindTypes != nil {
+		tmpl = tmplYAMLKinds
+		kindTypes = make(map[string]string, len(e.params.KindTypes))
+		for kind, typeName := range e.params.KindTypes {
+			kindTypes[kind] = f.types.Roots[typeName]
+		}
+	}
+	return mustRenderSrc(tmpl, TemplateData{
+		TypeDefinitions:         f.types.Definitions,
+		RootTypeName:            f.types.Root,
+		Input:                   input,
+		StdoutErrPrefix:         StdoutErrPrefix,
+		StdoutWarnPrefix:        StdoutWarnPrefix,
+		StdoutDefaultPrefix:     StdoutDefaultPrefix,
+		StdoutEffectivePrefix:   StdoutEffectivePrefix,
+		Imports:                 sortedKeys(f.types.Imports),
+		Enums:                   f.types.Enums,
+		KindTypes:               kindTypes,
+		Tag:                     f.tag,
+		FieldsRequiredByDefault: e.params.FieldsRequiredByDefault,
+		FailOnEmpty:             e.params.FailOnEmpty,
+		EnvExact:                e.params.EnvExact,
+		EnvStrict:               e.params.EnvStrict,
+		EnvPrefix:               e.params.EnvPrefix,
+		EnvCaseInsensitive:      e.params.EnvCaseInsensitive,
+		ReportDefaults:          e.params.ReportDefaults != "" || e.params.ReportUnused,
+		WarnZero:                e.params.WarnZero,
+		SOPS:                    e.params.SOPS,
+		StrictStrings:           e.params.StrictStrings,
+		MultiDoc:                e.params.MultiDoc,
+		EffectiveFormat:         f.effectiveFormat,
+	})
+}
+
+// formatProgram returns the program template, go.mod, go.sum
+// and vendor archive of the generated program for inputs of type t.
+func formatProgram(t InputType) (
+	tmpl *template.Template, goMod, goSum, vendorArchive []byte,
+) {
+	switch t {
+	case InputTypeENV, InputTypeDOTENV, InputTypeENVRC:
+		return tmplENV, gomodENV, gosumENV, vendorENV
+	case InputTypeTOML:
+		return tmplTOML, gomodTOML, gosumTOML, vendorTOML
+	case InputTypeJSON, InputTypeJSONNET, InputTypeCUE, InputTypeDHALL:
+		return tmplJSON, gomodJSON, gosumJSON, vendorJSON
+	case InputTypeYAML:
+		return tmplYAML, gomodYAML, gosumYAML, vendorYAML
+	case InputTypeHCL:
+		return tmplHCL, gomodHCL, gosumHCL, vendorHCL
+	case InputTypeXML:
+		// encoding/xml is part of the standard library,
+		// the dependencies are the same as for JSON.
+		return tmplXML, gomodJSON, gosumJSON, vendorJSON
+	case InputTypeINI:
+		return tmplINI, gomodINI, gosumINI, vendorINI
+	}
+	panic(fmt.Errorf("unknown input type: %d", t))
+}
+
+// formatExtension returns the file name extension of inputs of type t.
+func formatExtension(t InputType) string {
+	switch t {
+	case InputTypeTOML:
+		return ".toml"
+	case InputTypeJSON:
+		return ".json"
+	case InputTypeJSONNET:
+		return ".jsonnet"
+	case InputTypeYAML:
+		return ".yaml"
+	case InputTypeHCL:
+		return ".hcl"
+	case InputTypeXML:
+		return ".xml"
+	case InputTypeINI:
+		return ".ini"
+	case InputTypeCUE:
+		return ".cue"
+	case InputTypeDHALL:
+		return ".dhall"
+	}
+	return ""
+}
@@ -0,0 +1,471 @@
+package valfile
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct {
+			Port int "json:\"port\" yaml:\"port\" validate:\"gt=0\""
+		}
+	`), 0o644))
+
+	tmpDir := t.TempDir()
+	e := NewEngine(Params{PackageDir: pkgDir, TypeName: "Config"}, func() string {
+		return tmpDir
+	})
+
+	for _, td := range []struct {
+		format     InputType
+		data       string
+		expectErrs []string
+	}{
+		{InputTypeJSON, `{"port":80}`, nil},
+		{InputTypeJSON, `{"port":0}`, []string{
+			"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+		}},
+		{InputTypeJSON, `{"host":"x"}`, []string{`json: unknown field "host"`}},
+		{InputTypeYAML, "port: 443\n", nil},
+		{InputTypeYAML, "port: 8080\n", nil},
+	} {
+		errs := e.Validate(td.format, []byte(td.data))
+		if td.expectErrs == nil {
+			require.Nil(t, errs, "unexpected errors for %s: %v", td.data, errs)
+			continue
+		}
+		require.Equal(t, td.expectErrs, toStrings(errs), td.data)
+	}
+
+	// One temporary module per input format.
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.NoError(t, e.Close())
+	entries, err = os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+func TestEngineReportDefaults(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		import "time"
+		type Config struct {
+			Port    int           "yaml:\"port\" valfile:\"required\""
+			Host    string        "yaml:\"host\""
+			Timeout time.Duration "yaml:\"timeout\""
+		}
+	`), 0o644))
+
+	e := NewEngine(Params{
+		PackageDir:     pkgDir,
+		TypeName:       "Config",
+		ReportDefaults: ReportDefaultsJSON,
+	}, t.TempDir)
+	defer e.Close()
+
+	errs := e.Validate(InputTypeYAML, []byte("port: 80\n"))
+	require.Equal(t, []string{
+		`info: Config.Host: not set, defaults to ""`,
+		"info: Config.Timeout: not set, defaults to 0s",
+	}, toStrings(errs))
+	require.False(t, Result{Errs: errs}.Failed())
+	require.Equal(t, &DefaultedField{
+		Path: "Config.Timeout", Key: "timeout", Default: "0s",
+	}, asDiagnostic(errs[1]).Defaulted)
+}
+
+func TestEnginePrintEffective(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct {
+			Port    int   "json:\"port\" yaml:\"port\" valfile:\"aliases=listen_port\""
+			MaxSize int64 "json:\"max_size\" yaml:\"max_size\" valfile:\"bytesize\""
+		}
+	`), 0o644))
+
+	for _, td := range []struct {
+		effectiveFormat string
+		format          InputType
+		data            string
+		expect          string
+	}{
+		{"", InputTypeYAML, "listen_port: 80\nmax_size: 1KiB\n",
+			"port: 80\nmax_size: 1024\n"},
+		{"", InputTypeYAML, "# Limits\n" +
+			"max_size: 1KiB # maximum size\n" +
+			"\n" +
+			"# Listener\n" +
+			"port: 80\n",
+			"# Limits\n" +
+				"max_size: 1024 # maximum size\n" +
+				"# Listener\n" +
+				"port: 80\n"},
+		{"json", InputTypeYAML, "port: 80\n",
+			"{\n  \"port\": 80,\n  \"max_size\": 0\n}\n"},
+		{"", InputTypeJSON, `{"port":80}`,
+			"{\n  \"port\": 80,\n  \"max_size\": 0\n}\n"},
+	} {
+		e := NewEngine(Params{
+			PackageDir:      pkgDir,
+			TypeName:        "Config",
+			PrintEffective:  true,
+			EffectiveFormat: td.effectiveFormat,
+		}, t.TempDir)
+		errs := e.Validate(td.format, []byte(td.data))
+		require.NoError(t, e.Close())
+		require.False(t, Result{Errs: errs}.Failed(), "errors: %v", errs)
+
+		var b bytes.Buffer
+		require.NoError(t, writeEffective(&b, Report{Results: []Result{{Errs: errs}}}))
+		require.Equal(t, td.expect, b.String())
+	}
+}
+
+func TestEngineEmbeddedInput(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "env:\"PORT\" validate:\"gt=0\"" }
+	`), 0o644))
+
+	e := NewEngine(Params{PackageDir: pkgDir, TypeName: "Config"}, t.TempDir)
+	defer e.Close()
+	f, errs := e.format(InputTypeENV)
+	require.Nil(t, errs)
+
+	// Without arguments the program validates the embedded input.
+	src := e.renderProgram(InputTypeENV, f, map[string]string{"PORT": "0"})
+	require.NoError(t, os.WriteFile(filepath.Join(f.dir, "main.go"), src, 0o644))
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = f.dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.Equal(t, []string{
+		"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+	}, toStrings(parseProgramOutput(output)))
+}
+
+func TestEngineMissingToolchain(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	e := NewEngine(Params{PackageDir: t.TempDir(), TypeName: "Config"}, t.TempDir)
+	defer e.Close()
+	errs := e.Validate(InputTypeJSON, []byte(`{}`))
+	require.Equal(t, []error{ErrMissingToolchain}, errs)
+}
+
+func TestEngineGoCommand(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	require.NoError(t, err)
+	t.Setenv("PATH", t.TempDir())
+
+	pkgDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "json:\"port\"" }
+	`), 0o644))
+
+	e := NewEngine(Params{
+		PackageDir: pkgDir, TypeName: "Config", GoCommand: goCmd,
+	}, t.TempDir)
+	defer e.Close()
+	require.Nil(t, e.Validate(InputTypeJSON, []byte(`{"port":80}`)))
+
+	missing := filepath.Join(t.TempDir(), "go")
+	e = NewEngine(Params{
+		PackageDir: pkgDir, TypeName: "Config", GoCommand: missing,
+	}, t.TempDir)
+	defer e.Close()
+	errs := e.Validate(InputTypeJSON, []byte(`{"port":80}`))
+	require.Len(t, errs, 1)
+	require.Equal(t, CodeToolchain, asDiagnostic(errs[0]).Code)
+	require.ErrorContains(t, errs[0], "go command "+missing+" not found: ")
+}
+
+func TestGoCommandEnv(t *testing.T) {
+	require.Equal(t, []string{
+		"HOME=/home/u",
+		"PATH=/usr/bin",
+		"GOPROXY=off",
+		"GOFLAGS=-mod=vendor",
+		"GOCACHE=/tmp/cache",
+		"GOWORK=off",
+	}, goCommandEnv([]string{
+		"GOFLAGS=-mod=vendor",
+		"HOME=/home/u",
+		"GOWORK=/home/u/go.work",
+		"GOCACHE=/tmp/cache",
+		"PATH=/usr/bin",
+		"GOPROXY=off",
+	}))
+}
+
+func TestEngineGoFlags(t *testing.T) {
+	// Offline with vendoring forced by the caller.
+	t.Setenv("GOFLAGS", "-mod=vendor")
+	t.Setenv("GOPROXY", "off")
+
+	pkgDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "yaml:\"port\"" }
+	`), 0o644))
+
+	e := NewEngine(Params{PackageDir: pkgDir, TypeName: "Config"}, t.TempDir)
+	defer e.Close()
+	require.Nil(t, e.Validate(InputTypeYAML, []byte("port: 80\n")))
+}
+
+func TestToolchainError(t *testing.T) {
+	_, err := runToolchain("running generated program", exec.Command("go", "nonexistent"))
+	var te *ToolchainError
+	require.ErrorAs(t, err, &te)
+	require.Contains(t, string(te.Stderr), "unknown command")
+	require.Equal(t, "running generated program: exit status 2\n"+
+		strings.TrimSpace(string(te.Stderr)), err.Error())
+	require.Equal(t, CodeToolchain, asDiagnostic(err).Code)
+	require.Equal(t, []error{err}, withCode(CodeToolchain, err))
+
+	te.Dir = "/tmp/valfile-1"
+	require.True(t, strings.HasPrefix(err.Error(),
+		"running generated program in /tmp/valfile-1: exit status 2\n"))
+
+	require.False(t, hasReportedErrors([]byte("panic: oops\n")))
+	require.False(t, hasReportedErrors([]byte(StdoutWarnPrefix+"warning\n")))
+	require.True(t, hasReportedErrors([]byte(StdoutErrPrefix+"invalid\npanic: oops\n")))
+	require.True(t, hasReportedErrors([]byte(StdoutWarnPrefix+"w\n"+StdoutErrPrefix+"e\n")))
+}
+
+func TestEngineCompileCheck(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		const N = 3
+		type Config struct { Ports [N]int "yaml:\"ports\"" }
+		type Valid struct { Port int "yaml:\"port\"" }
+	`), 0o644))
+
+	e := NewEngine(Params{PackageDir: pkgDir, TypeName: "Valid"}, t.TempDir)
+	defer e.Close()
+	require.Nil(t, e.CompileCheck(InputTypeYAML))
+
+	// Constants aren't copied to the generated program.
+	e = NewEngine(Params{PackageDir: pkgDir, TypeName: "Config"}, t.TempDir)
+	defer e.Close()
+	errs := e.CompileCheck(InputTypeYAML)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "compiling generated program: exit status 1\n")
+	require.ErrorContains(t, errs[0], "undefined array length N")
+	var te *ToolchainError
+	require.ErrorAs(t, errs[0], &te)
+	require.Contains(t, string(te.Stderr), "undefined array length N")
+
+	// Compilation errors are reported for every input.
+	for i := 0; i < 2; i++ {
+		errs = e.Validate(InputTypeYAML, []byte("ports: [1, 2, 3]\n"))
+		require.Len(t, errs, 1)
+		require.Equal(t, CodeToolchain, asDiagnostic(errs[0]).Code)
+		require.ErrorContains(t, errs[0], "compiling generated program: exit status 1\n")
+	}
+}
+
+func TestSetupWorkspace(t *testing.T) {
+	src := []byte("package main\n\nfunc main() {}\n")
+
+	tmpDir := t.TempDir()
+	e := NewEngine(Params{}, func() string { return tmpDir })
+	dir, cleanup, err := e.setupWorkspace(InputTypeYAML, src)
+	require.NoError(t, err)
+	require.Equal(t, tmpDir, filepath.Dir(dir))
+	for _, f := range []string{"go.mod", "go.sum", "vendor/modules.txt"} {
+		require.FileExists(t, filepath.Join(dir, f))
+	}
+	require.DirExists(t, filepath.Join(dir, "input"))
+	b, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, src, b)
+	require.NoError(t, cleanup())
+	require.NoDirExists(t, dir)
+
+	// Persistent workspaces set up the module of a format only once.
+	workspace := t.TempDir()
+	e = NewEngine(Params{Workspace: workspace}, nil)
+	dir, cleanup, err = e.setupWorkspace(InputTypeYAML, src)
+	require.NoError(t, err)
+	module := filepath.Join(workspace, "yaml")
+	require.Equal(t, module, filepath.Dir(dir))
+	require.FileExists(t, filepath.Join(module, "vendor/modules.txt"))
+	require.FileExists(t, filepath.Join(dir, "main.go"))
+	require.NoError(t, cleanup())
+	require.DirExists(t, dir)
+
+	marker := filepath.Join(module, "vendor", "marker")
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+	dirOther, _, err := e.setupWorkspace(InputTypeYAML, []byte("package main\n"))
+	require.NoError(t, err)
+	require.NotEqual(t, dir, dirOther)
+	require.FileExists(t, marker)
+
+	// Outdated modules are replaced.
+	stamp := filepath.Join(module, moduleStampFile)
+	require.NoError(t, os.WriteFile(stamp, []byte("outdated"), 0o644))
+	_, _, err = e.setupWorkspace(InputTypeYAML, src)
+	require.NoError(t, err)
+	require.NoFileExists(t, marker)
+	require.FileExists(t, filepath.Join(module, "vendor/modules.txt"))
+}
+
+func TestEngineKeepTemp(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		const N = 3
+		type Config struct { Ports [N]int "yaml:\"ports\"" }
+	`), 0o644))
+
+	tmpDir := t.TempDir()
+	p := Params{PackageDir: pkgDir, TypeName: "Config", KeepTemp: true}
+	e := NewEngine(p, func() string { return tmpDir })
+	errs := e.CompileCheck(InputTypeYAML)
+	require.Len(t, errs, 1)
+	require.NoError(t, e.Close())
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	dir := filepath.Join(tmpDir, entries[0].Name())
+	require.FileExists(t, filepath.Join(dir, "main.go"))
+	require.ErrorContains(t, errs[0], "compiling generated program in "+dir+": ")
+
+	// Directories of programs compiled successfully are reported once.
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "yaml:\"port\"" }
+	`), 0o644))
+	tmpDir = t.TempDir()
+	e = NewEngine(p, func() string { return tmpDir })
+	defer e.Close()
+	errs = e.CompileCheck(InputTypeYAML)
+	entries, err = os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, []error{&Diagnostic{
+		Severity: SeverityInfo,
+		Code:     CodeTempDir,
+		Message:  filepath.Join(tmpDir, entries[0].Name()),
+	}}, errs)
+	require.Nil(t, e.Validate(InputTypeYAML, []byte("port: 80\n")))
+}
+
+func TestEngineCache(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "yaml:\"port\"" }
+		type Other struct { Host string "yaml:\"host\"" }
+	`), 0o644))
+
+	// Programs that weren't used for a while are pruned.
+	cacheDir := t.TempDir()
+	old := time.Now().Add(-unusedProgramAge - time.Hour)
+	for _, name := range []string{"program-unused", "program-used"} {
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, name), nil, 0o755))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(cacheDir, "program-unused"), old, old))
+
+	p := Params{PackageDir: pkgDir, TypeName: "Config", CacheDir: cacheDir}
+	e := NewEngine(p, t.TempDir)
+	require.Nil(t, e.Validate(InputTypeYAML, []byte("port: 80\n")))
+	require.NoError(t, e.Close())
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.True(t, strings.HasPrefix(entries[0].Name(), "program-"))
+	require.Equal(t, "program-used", entries[1].Name())
+
+	// The go command is part of the cache key.
+	require.NotEqual(t,
+		NewEngine(p, t.TempDir).cachedProgram(InputTypeYAML, nil),
+		NewEngine(Params{GoCommand: "go1.21.0"}, t.TempDir).
+			cachedProgram(InputTypeYAML, nil))
+
+	// Cached programs run without the Go toolchain.
+	t.Setenv("PATH", t.TempDir())
+	e = NewEngine(p, t.TempDir)
+	defer e.Close()
+	require.Nil(t, e.CompileCheck(InputTypeYAML))
+	require.Nil(t, e.Validate(InputTypeYAML, []byte("port: 80\n")))
+	require.Equal(t, []string{"yaml: unmarshal errors:\n" +
+		"  line 1: field host not found in type main.Config"},
+		toStrings(e.Validate(InputTypeYAML, []byte("host: x\n"))))
+
+	// Programs that aren't cached require it.
+	p.TypeName = "Other"
+	e = NewEngine(p, t.TempDir)
+	defer e.Close()
+	require.Equal(t, []error{ErrMissingToolchain},
+		e.Validate(InputTypeYAML, []byte("port: 80\n")))
+}
+
+func TestEngineWorkspace(t *testing.T) {
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "yaml:\"port\"" }
+		type Other struct { Host string "yaml:\"host\"" }
+	`), 0o644))
+
+	workspace := t.TempDir()
+	p := Params{PackageDir: pkgDir, TypeName: "Config", Workspace: workspace}
+	for i := 0; i < 2; i++ {
+		e := NewEngine(p, t.TempDir)
+		require.Nil(t, e.Validate(InputTypeYAML, []byte("port: 80\n")))
+		require.Equal(t, []string{"yaml: unmarshal errors:\n" +
+			"  line 1: field host not found in type main.Config"},
+			toStrings(e.Validate(InputTypeYAML, []byte("host: x\n"))))
+		require.NoError(t, e.Close())
+	}
+	entries, err := os.ReadDir(filepath.Join(workspace, "yaml"))
+	require.NoError(t, err)
+	var programs int
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "program-") {
+			programs++
+		}
+	}
+	require.Equal(t, 1, programs)
+
+	// Programs that weren't used for a while are pruned
+	// when a new one is written.
+	unused := filepath.Join(workspace, "yaml", "program-unused")
+	require.NoError(t, os.MkdirAll(unused, 0o755))
+	old := time.Now().Add(-unusedProgramAge - time.Hour)
+	require.NoError(t, os.Chtimes(unused, old, old))
+	p.TypeName = "Other"
+	e := NewEngine(p, t.TempDir)
+	require.Nil(t, e.Validate(InputTypeYAML, []byte("host: x\n")))
+	require.NoError(t, e.Close())
+	require.NoDirExists(t, unused)
+}
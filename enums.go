@@ -0,0 +1,54 @@
+package valfile
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"slices"
+)
+
+// findEnumValues returns the values of the constants declared in pkg
+// for each of the types typeNames with an underlying integer type,
+// formatted as decimal numbers. Types without constants are omitted.
+// The package is type-checked to evaluate constant expressions such as iota,
+// errors are ignored as long as the constants can be evaluated.
+func findEnumValues(
+	fset *token.FileSet, pkg *ast.Package, typeNames []string,
+) map[string][]string {
+	files := make([]*ast.File, 0, len(pkg.Files))
+	for _, k := range sortedKeys(pkg.Files) {
+		files = append(files, pkg.Files[k])
+	}
+	conf := types.Config{Importer: importer.Default(), Error: func(error) {}}
+	tpkg, _ := conf.Check(pkg.Name, fset, files, nil)
+	if tpkg == nil {
+		return nil
+	}
+
+	isEnumType := make(map[string]bool, len(typeNames))
+	for _, n := range typeNames {
+		isEnumType[n] = true
+	}
+	enums := map[string][]string{}
+	for _, name := range tpkg.Scope().Names() {
+		c, ok := tpkg.Scope().Lookup(name).(*types.Const)
+		if !ok || c.Val().Kind() != constant.Int {
+			continue
+		}
+		named, ok := c.Type().(*types.Named)
+		if !ok || named.Obj().Pkg() != tpkg || !isEnumType[named.Obj().Name()] {
+			continue
+		}
+		basic, ok := named.Underlying().(*types.Basic)
+		if !ok || basic.Info()&types.IsInteger == 0 {
+			continue
+		}
+		t := named.Obj().Name()
+		if v := c.Val().ExactString(); !slices.Contains(enums[t], v) {
+			enums[t] = append(enums[t], v)
+		}
+	}
+	return enums
+}
@@ -0,0 +1,44 @@
+package valfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+var regexEnvrcAssignment = regexp.MustCompile(
+	`^(export\s+)?[A-Za-z_][A-Za-z0-9_]*=`,
+)
+
+// parseEnvrc returns the variables assigned by a direnv .envrc file,
+// which are lines such as "export FOO=bar" or "FOO=bar".
+// Other shell statements, such as "use nix" or "source_up", are ignored
+// unless strict is true, in which case they're reported as an error.
+func parseEnvrc(data []byte, strict bool) (map[string]string, error) {
+	vars := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if !regexEnvrcAssignment.MatchString(l) {
+			if strict {
+				return nil, fmt.Errorf("line %d: unsupported statement: %q", line, l)
+			}
+			continue
+		}
+		m, err := godotenv.Unmarshal(l)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+	return vars, s.Err()
+}
@@ -0,0 +1,29 @@
+package valfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvrc(t *testing.T) {
+	src := []byte(`# development environment
+use nix
+source_up
+
+export DB_HOST=localhost
+export DB_PORT="5432"
+LOG_LEVEL='debug' # inline comment
+PATH_add bin
+`)
+	vars, err := parseEnvrc(src, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"DB_HOST":   "localhost",
+		"DB_PORT":   "5432",
+		"LOG_LEVEL": "debug",
+	}, vars)
+
+	_, err = parseEnvrc(src, true)
+	require.EqualError(t, err, `line 2: unsupported statement: "use nix"`)
+}
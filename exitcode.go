@@ -0,0 +1,85 @@
+package valfile
+
+import "errors"
+
+// Exit codes of valfile.
+const (
+	ExitOK = 0
+
+	// ExitFailure is returned for failures of no other class,
+	// such as an unreadable input file.
+	ExitFailure = 1
+
+	// ExitUsage is returned for invalid parameters.
+	ExitUsage = 2
+
+	// ExitType is returned if the type can't be resolved
+	// or its marshaling tags are invalid.
+	ExitType = 3
+
+	// ExitInvalid is returned if an input doesn't match the type.
+	ExitInvalid = 4
+
+	// ExitToolchain is returned if the Go toolchain is missing
+	// or failed to run the generated program.
+	ExitToolchain = 5
+)
+
+// exitCodesUsage documents the exit codes in the usage message.
+const exitCodesUsage = `
+Exit codes:
+  0	all inputs are valid
+  1	any other failure, such as an unreadable input file
+  2	invalid parameters
+  3	the type can't be resolved or its marshaling tags are invalid
+  4	an input doesn't match the type
+  5	the Go toolchain is missing or failed to run the generated program
+`
+
+// exitCodePriority lists the exit codes of failure classes, of which the
+// first one that occurred is returned, in the order of their precedence.
+// Any other class hides an invalid input since it may be caused by it.
+var exitCodePriority = []int{
+	ExitToolchain, ExitUsage, ExitType, ExitFailure, ExitInvalid,
+}
+
+// ExitCode returns the exit code of the most severe class of failure of r,
+// or ExitOK if r didn't fail.
+func (r Report) ExitCode() int {
+	occurred := map[int]bool{}
+	add := func(errs []error) {
+		for _, err := range errs {
+			if severityOf(err) == SeverityError {
+				occurred[exitCode(err)] = true
+			}
+		}
+	}
+	add(r.Errs)
+	for _, res := range r.Results {
+		add(res.Errs)
+	}
+	for _, c := range exitCodePriority {
+		if occurred[c] {
+			return c
+		}
+	}
+	return ExitOK
+}
+
+// exitCode returns the exit code of the class of err.
+func exitCode(err error) int {
+	if errors.Is(err, ErrMissingToolchain) {
+		return ExitToolchain
+	}
+	switch asDiagnostic(err).Code {
+	case CodeUsage:
+		return ExitUsage
+	case CodeType, CodeTag:
+		return ExitType
+	case CodeInvalid:
+		return ExitInvalid
+	case CodeToolchain:
+		return ExitToolchain
+	}
+	return ExitFailure
+}
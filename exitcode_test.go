@@ -0,0 +1,52 @@
+package valfile
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportExitCode(t *testing.T) {
+	invalid := newInvalidInputDiagnostic("json: unknown field \"foo\"")
+	tag := &Diagnostic{Code: CodeTag, Message: `Config.Foo: missing tag "json"`}
+	for _, td := range []struct {
+		name   string
+		report Report
+		expect int
+	}{
+		{"ok", Report{Results: []Result{{Input: "a.json"}}}, ExitOK},
+		{"warnings", Report{Results: []Result{{Errs: []error{
+			&Diagnostic{Severity: SeverityWarning, Code: CodeUnused},
+		}}}}, ExitOK},
+		{"invalid", Report{Results: []Result{{Errs: []error{invalid}}}}, ExitInvalid},
+		{"tag", Report{Results: []Result{
+			{Errs: []error{invalid}}, {Errs: []error{tag}},
+		}}, ExitType},
+		{"type", Report{Results: []Result{{Errs: withCode(
+			CodeType, errors.New(`type "Config" not found`),
+		)}}}, ExitType},
+		{"usage", Report{Results: []Result{{Errs: withCode(
+			CodeUsage, errors.New("-kind is only supported for YAML input"),
+		)}}}, ExitUsage},
+		{"failure", Report{Errs: []error{errors.New("unreadable config")}}, ExitFailure},
+		{"missing_toolchain", Report{Results: []Result{{Errs: []error{
+			ErrMissingToolchain,
+		}}}}, ExitToolchain},
+		{"toolchain", Report{Results: []Result{
+			{Errs: []error{invalid}},
+			{Errs: withCode(CodeToolchain, errors.New("exit status 1"))},
+		}}, ExitToolchain},
+		{"toolchain_error", Report{Results: []Result{{Errs: []error{
+			&ToolchainError{Op: "running generated program", Err: errors.New("exit status 2")},
+		}}}}, ExitToolchain},
+		{"any", Report{Results: []Result{{Errs: []error{
+			fmt.Errorf("ConfigV1: %w", invalid),
+		}}}}, ExitInvalid},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			require.Equal(t, td.expect, td.report.ExitCode())
+		})
+	}
+}
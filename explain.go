@@ -0,0 +1,56 @@
+package valfile
+
+import (
+	"fmt"
+	"io"
+)
+
+// explainType writes the types selected by p and all types they depend on,
+// in the order they were resolved, to w. Each type is preceded by a comment
+// with the position of its declaration. If p selects an input, the tags
+// are shown as seen by the generated program.
+func explainType(w io.Writer, p Params) []error {
+	inputType, err := selectedInputType(p)
+	if err != nil {
+		return []error{err}
+	}
+
+	fset, types, errs := NewEngine(p, nil).resolve(inputType)
+	if errs != nil {
+		return errs
+	}
+
+	tag := inputType.MarshalingTag()
+	if tag == "" {
+		tag = "unknown, no input selected"
+	}
+	rootTypeNames := []string{types.Root}
+	if p.KindTypes != nil {
+		rootTypeNames = sortedValues(p.KindTypes)
+	}
+	if _, err := fmt.Fprintf(w, "// root types: %v\n// tag: %s\n", rootTypeNames, tag); err != nil {
+		return []error{err}
+	}
+	for i, name := range types.Names {
+		_, err := fmt.Fprintf(w, "\n// %s\ntype %s\n",
+			fset.Position(types.Specs[name].Pos()), types.Definitions[i])
+		if err != nil {
+			return []error{err}
+		}
+	}
+	return nil
+}
+
+// selectedInputType returns the type of the input selected by p,
+// or 0 if p selects no input.
+func selectedInputType(p Params) (InputType, error) {
+	switch {
+	case p.InputEnv:
+		return InputTypeENV, nil
+	case p.MergePatch != "":
+		return InputTypeJSON, nil
+	case p.InputFile != "":
+		return getFileFormat(inputFileName(p))
+	}
+	return p.Format, nil
+}
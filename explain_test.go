@@ -0,0 +1,45 @@
+package valfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainType(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+type Config struct {
+	DB DB "env:\"DB_\""
+}
+
+type DB struct {
+	Host string "env:\"HOST\""
+}
+`), 0o644))
+
+	var b bytes.Buffer
+	errs := explainType(&b, Params{PackageDir: dir, TypeName: "Config", InputEnv: true})
+	require.Nil(t, errs)
+	require.Equal(t, `// root types: [Config]
+// tag: env
+
+// `+filepath.Join(dir, "main.go")+`:3:6
+type Config struct {
+	DB DB `+"`envPrefix:\"DB_\"`"+`
+}
+
+// `+filepath.Join(dir, "main.go")+`:7:6
+type DB struct {
+	Host string "env:\"HOST\""
+}
+`, b.String())
+
+	b.Reset()
+	errs = explainType(&b, Params{PackageDir: dir, TypeName: "Missing"})
+	require.Equal(t, []string{"type Missing not found in package main\n"}, toStrings(errs))
+}
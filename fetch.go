@@ -0,0 +1,57 @@
+package valfile
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default timeout of requests fetching input URLs.
+const DefaultTimeout = 30 * time.Second
+
+// isURL returns true if path is an HTTP or HTTPS URL rather than a file path.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// urlPath returns the path of the URL s, which the input format
+// is detected by, or s itself if it can't be parsed.
+func urlPath(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Path
+}
+
+// fetchInput fetches the body of the input URL p.InputFile,
+// which must be served with status 200 within p.Timeout.
+// Bodies larger than p.MaxInputSize aren't read entirely.
+func fetchInput(p Params) ([]byte, error) {
+	c := http.Client{Timeout: p.Timeout}
+	resp, err := c.Get(p.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("fetching input: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"fetching input: %s: unexpected status %s", p.InputFile, resp.Status,
+		)
+	}
+	r := io.Reader(resp.Body)
+	if p.MaxInputSize > 0 {
+		r = io.LimitReader(r, p.MaxInputSize+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("fetching input: %w", err)
+	}
+	if err := checkInputSize(p, int64(len(b))); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
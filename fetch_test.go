@@ -0,0 +1,95 @@
+package valfile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchInput(t *testing.T) {
+	cacheDir := t.TempDir()
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+	defer func() { userCacheDir = os.UserCacheDir }()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.json":
+			_, _ = w.Write([]byte(`{"port":8080}`))
+		case "/invalid.json":
+			_, _ = w.Write([]byte(`{"port":0}`))
+		case "/large.json":
+			_, _ = w.Write([]byte(`{"port":8080,"padding":"` +
+				string(make([]byte, 64)) + `"}`))
+		case "/slow.json":
+			<-release
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	// Closed before the server, which waits for the slow handler.
+	defer close(release)
+
+	pkgDir := filepath.Join(t.TempDir(), "tstcmd")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(`
+		package main
+		type Config struct { Port int "json:\"port\" validate:\"gt=0\"" }
+	`), 0o644))
+
+	for _, td := range []struct {
+		Name       string
+		Args       []string
+		ExpectErrs []string
+	}{
+		{Name: "valid", Args: []string{"-f", srv.URL + "/app.json"}},
+		{Name: "query", Args: []string{"-f", srv.URL + "/app.json?env=prod"}},
+		{
+			Name: "invalid",
+			Args: []string{"-f", srv.URL + "/invalid.json"},
+			ExpectErrs: []string{
+				"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "not_found",
+			Args: []string{"-f", srv.URL + "/missing.json"},
+			ExpectErrs: []string{
+				"reading input file: fetching input: " + srv.URL +
+					"/missing.json: unexpected status 404 Not Found",
+			},
+		},
+		{
+			Name: "max_input_size",
+			Args: []string{"-f", srv.URL + "/large.json", "-max-input-size", "32"},
+			ExpectErrs: []string{
+				"reading input file: input size 33 bytes exceeds the maximum " +
+					"of 32 bytes, see -max-input-size",
+			},
+		},
+		{
+			Name: "timeout",
+			Args: []string{"-f", srv.URL + "/slow.json", "-timeout", "50ms"},
+			ExpectErrs: []string{
+				"reading input file: fetching input: Get \"" + srv.URL +
+					"/slow.json\": context deadline exceeded " +
+					"(Client.Timeout exceeded while awaiting headers)",
+			},
+		},
+	} {
+		t.Run(td.Name, func(t *testing.T) {
+			args := append([]string{"valfile", "-p", pkgDir, "-t", "Config"}, td.Args...)
+			errs := run(args, t.TempDir, func() []string { return nil })
+			if td.ExpectErrs == nil {
+				require.Nil(t, errs, "unexpected errors: %v", errs)
+				return
+			}
+			require.Equal(t, td.ExpectErrs, toStrings(errs))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package valfile
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fieldsFileName is the name of the file declaring the type
+// added by addFieldsType.
+const fieldsFileName = "valfile_fields.go"
+
+// addFieldsType adds a struct type to pkg, which is located in dir, with
+// a field for each key of fieldTypes, which maps keys of inputs of type t
+// to the names of the types their values are validated against,
+// and returns its name. The fields are named after their types,
+// such that errors name the type that failed, and tagged with the keys.
+func addFieldsType(
+	fset *token.FileSet, pkg *ast.Package, dir string, t InputType,
+	fieldTypes map[string]string,
+) (string, error) {
+	name := "Input"
+	for i := 2; findType(fset, pkg, name) != nil; i++ {
+		name = "Input" + strconv.Itoa(i)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\ntype %s struct {\n", pkg.Name, name)
+	taken := map[string]bool{}
+	for _, key := range sortedKeys(fieldTypes) {
+		typeName := fieldTypes[key]
+		base, _, _ := strings.Cut(typeName, "[")
+		r, size := utf8.DecodeRuneInString(base)
+		fieldName := string(unicode.ToUpper(r)) + base[size:]
+		for i := 2; taken[fieldName]; i++ {
+			fieldName = string(unicode.ToUpper(r)) + base[size:] + strconv.Itoa(i)
+		}
+		taken[fieldName] = true
+		if t == InputTypeHCL {
+			// gohcl decodes structs from blocks only.
+			key += ",block"
+		}
+		fmt.Fprintf(&b, "\t%s %s `%s:%q`\n", fieldName, typeName, t.MarshalingTag(), key)
+	}
+	b.WriteString("}\n")
+
+	fileName := filepath.Join(dir, fieldsFileName)
+	f, err := parser.ParseFile(fset, fileName, b.String(), 0)
+	if err != nil {
+		return "", fmt.Errorf("invalid type in -field mappings: %w", err)
+	}
+	pkg.Files[fileName] = f
+	// Rebuilt including the added type on the next lookup.
+	pkg.Scope = nil
+	return name, nil
+}
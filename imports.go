@@ -0,0 +1,692 @@
+package valfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// importedPackage is a package whose types are resolved by a typeResolver.
+type importedPackage struct {
+	// path is the import path, empty for the package of the root types
+	// if it isn't part of a module.
+	path string
+	pkg  *ast.Package
+}
+
+// typeResolver resolves the types the root types depend on, including
+// types of other packages of the same module or its vendor directory,
+// which are inlined into the package of the root types. Qualified
+// identifiers referring to them are rewritten to their local names,
+// which are their original names unless taken by another type.
+// Instantiations of generic types are inlined as non-generic types
+// with the type arguments substituted for the type parameters.
+// Aliases of inlined types are replaced by the types they denote.
+type typeResolver struct {
+	fset *token.FileSet
+	std  types.Importer
+	r    *resolvedTypes
+	main *importedPackage
+
+	// modRoot and modPath are the directory and the path of the module
+	// of the package of the root types, empty if it isn't part of a module.
+	modRoot, modPath string
+
+	// packages are the imported packages by import path.
+	packages map[string]*importedPackage
+
+	// imports are the paths of the packages each package imports types from.
+	imports map[string][]string
+
+	// local maps the qualified names of types of imported packages
+	// and of instantiations of generic types to their local names.
+	local map[string]string
+
+	// aliases are the qualified names of the aliases being resolved.
+	aliases []string
+
+	// errs are the errors of all types that failed to resolve,
+	// each reported once regardless of how often the type is referred to.
+	errs     []error
+	reported map[string]bool
+}
+
+// fail adds err to res.errs unless an identical error was already added.
+func (res *typeResolver) fail(err error) {
+	if res.reported[err.Error()] {
+		return
+	}
+	if res.reported == nil {
+		res.reported = map[string]bool{}
+	}
+	res.reported[err.Error()] = true
+	res.errs = append(res.errs, err)
+}
+
+func newTypeResolver(
+	fset *token.FileSet, pkg *ast.Package, r *resolvedTypes,
+) *typeResolver {
+	res := &typeResolver{
+		fset:     fset,
+		std:      importer.Default(),
+		r:        r,
+		main:     &importedPackage{pkg: pkg},
+		packages: map[string]*importedPackage{},
+		imports:  map[string][]string{},
+		local:    map[string]string{},
+	}
+	for _, k := range sortedKeys(pkg.Files) {
+		dir := filepath.Dir(fset.Position(pkg.Files[k].Package).Filename)
+		res.modRoot, res.modPath = findModule(dir)
+		if res.modRoot != "" {
+			rel, err := filepath.Rel(res.modRoot, dir)
+			if err == nil {
+				res.main.path = path.Join(res.modPath, filepath.ToSlash(rel))
+			}
+		}
+		break
+	}
+	return res
+}
+
+// root resolves the root type expr, which is the name of a type of the
+// package of the root types or an instantiation of a generic type of it,
+// such as Config[Prod], and returns its local name.
+func (res *typeResolver) root(expr string) (string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q", expr)
+	}
+	x, typeArgs := splitTypeArgs(e)
+	name, ok := x.(*ast.Ident)
+	if !ok {
+		return "", fmt.Errorf("invalid type %q", expr)
+	}
+	spec := findType(res.fset, res.main.pkg, name.Name)
+	if spec == nil {
+		return "", fmt.Errorf("type %s not found in package %s\n", name.Name, res.main.pkg.Name)
+	}
+	if typeArgs == nil {
+		if spec.TypeParams != nil {
+			return "", fmt.Errorf("type %s is generic, "+
+				"specify its type arguments, e.g. -t '%s[%s]'",
+				name.Name, name.Name, typeParamNames(spec)[0])
+		}
+		return res.resolve(res.main, spec), nil
+	}
+	for i, a := range typeArgs {
+		typeArgs[i] = res.walk(res.main, a, nil)
+	}
+	return res.instantiate(res.main, spec, typeArgs), nil
+}
+
+// add adds the type spec of p under its local name and resolves the types
+// it depends on. Type parameters are substituted by the type arguments
+// in typeArgs, which are already resolved.
+func (res *typeResolver) add(
+	p *importedPackage, spec *ast.TypeSpec, local string,
+	typeArgs map[string]ast.Expr,
+) {
+	spec.Name.Name = local
+	res.r.Specs[local] = spec
+	res.r.Names = append(res.r.Names, local)
+	spec.Type = res.walk(p, spec.Type, typeArgs)
+}
+
+// walk resolves the types referred to by the type expression e of package p
+// and returns e with qualified identifiers rewritten to local names,
+// instantiations rewritten to the local names of the instantiated types
+// and the type parameters in typeArgs substituted.
+func (res *typeResolver) walk(
+	p *importedPackage, e ast.Expr, typeArgs map[string]ast.Expr,
+) ast.Expr {
+	switch t := e.(type) {
+	case *ast.ChanType, *ast.FuncType:
+	case *ast.StarExpr:
+		t.X = res.walk(p, t.X, typeArgs)
+	case *ast.StructType:
+		for _, f := range t.Fields.List {
+			f.Type = res.walk(p, f.Type, typeArgs)
+		}
+	case *ast.ArrayType:
+		t.Elt = res.walk(p, t.Elt, typeArgs)
+	case *ast.MapType:
+		t.Key = res.walk(p, t.Key, typeArgs)
+		t.Value = res.walk(p, t.Value, typeArgs)
+	case *ast.SelectorExpr:
+		return res.selector(p, t)
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return res.instance(p, t, typeArgs)
+	case *ast.Ident:
+		if a, ok := typeArgs[t.Name]; ok {
+			return cloneTypeExpr(a)
+		}
+		res.ident(p, t)
+	}
+	return e
+}
+
+// ident resolves the type of package p referred to by i
+// and renames i to its local name.
+func (res *typeResolver) ident(p *importedPackage, i *ast.Ident) {
+	if isTypePrimitive(i.Name) {
+		return
+	}
+	spec := findType(res.fset, p.pkg, i.Name)
+	if spec == nil {
+		importPath, err := findDotImport(res.std, p.pkg, i.Name)
+		if err != nil {
+			res.fail(err)
+			return
+		}
+		if importPath != "" {
+			res.r.Imports[". "+strconv.Quote(importPath)] = struct{}{}
+			return
+		}
+		res.fail(fmt.Errorf(
+			"undefined type: %s", res.qualifiedName(p, i.Name),
+		))
+		return
+	}
+	if spec.TypeParams != nil {
+		res.fail(fmt.Errorf(
+			"cannot use generic type %s without instantiation",
+			res.qualifiedName(p, i.Name),
+		))
+		return
+	}
+	i.Name = res.resolve(p, spec)
+}
+
+// selector resolves the type of another package referred to by the
+// qualified identifier s in package p and returns the identifier replacing s,
+// or s itself if it refers to a type of the standard library.
+func (res *typeResolver) selector(p *importedPackage, s *ast.SelectorExpr) ast.Expr {
+	x, ok := s.X.(*ast.Ident)
+	if !ok {
+		res.fail(fmt.Errorf("unsupported type expression: %T", s.X))
+		return s
+	}
+	imported, spec, ok := res.lookupSelector(p, s)
+	if !ok || imported == nil {
+		return s
+	}
+	if spec.TypeParams != nil {
+		res.fail(fmt.Errorf(
+			"cannot use generic type %s.%s without instantiation", x.Name, s.Sel.Name,
+		))
+		return s
+	}
+	return &ast.Ident{NamePos: s.Pos(), Name: res.resolve(imported, spec)}
+}
+
+// lookupSelector returns the imported package and the type spec referred
+// to by the qualified identifier s in package p, or a nil package if s
+// refers to a type of the standard library, whose import is added.
+// Returns false if the type can't be resolved.
+func (res *typeResolver) lookupSelector(p *importedPackage, s *ast.SelectorExpr) (
+	*importedPackage, *ast.TypeSpec, bool,
+) {
+	x := s.X.(*ast.Ident)
+	typeName := x.Name + "." + s.Sel.Name
+	importPath := res.findImport(p, x.Name)
+	if importPath == "" {
+		spec, err := findSelectorImport(res.std, p.pkg, s)
+		if err != nil {
+			res.fail(err)
+			return nil, nil, false
+		}
+		res.r.Imports[spec] = struct{}{}
+		return nil, nil, true
+	}
+	imported, err := res.importPackage(p, importPath)
+	if err != nil {
+		res.fail(fmt.Errorf("undefined type: %s: %w", typeName, err))
+		return nil, nil, false
+	}
+	spec := findType(res.fset, imported.pkg, s.Sel.Name)
+	if spec == nil || !ast.IsExported(s.Sel.Name) {
+		res.fail(fmt.Errorf("undefined type: %s", typeName))
+		return nil, nil, false
+	}
+	return imported, spec, true
+}
+
+// instance resolves the instantiation e of a generic type in package p
+// and returns the identifier of the instantiated type replacing e,
+// or e itself if the generic type is of the standard library.
+func (res *typeResolver) instance(
+	p *importedPackage, e ast.Expr, typeArgs map[string]ast.Expr,
+) ast.Expr {
+	x, args := splitTypeArgs(e)
+	for i, a := range args {
+		args[i] = res.walk(p, a, typeArgs)
+	}
+	var (
+		generic *importedPackage
+		spec    *ast.TypeSpec
+	)
+	switch x := x.(type) {
+	case *ast.Ident:
+		if spec = findType(res.fset, p.pkg, x.Name); spec == nil {
+			res.fail(fmt.Errorf(
+				"undefined type: %s", res.qualifiedName(p, x.Name),
+			))
+			return e
+		}
+		generic = p
+	case *ast.SelectorExpr:
+		if _, ok := x.X.(*ast.Ident); !ok {
+			res.fail(fmt.Errorf("unsupported type expression: %T", x.X))
+			return e
+		}
+		var ok bool
+		if generic, spec, ok = res.lookupSelector(p, x); !ok || generic == nil {
+			return e
+		}
+	default:
+		res.fail(fmt.Errorf("unsupported type expression: %T", x))
+		return e
+	}
+	return &ast.Ident{NamePos: e.Pos(), Name: res.instantiate(generic, spec, args)}
+}
+
+// instantiate returns the local name of the instantiation of the generic
+// type spec of p with the resolved type arguments typeArgs,
+// adding it on first use.
+func (res *typeResolver) instantiate(
+	p *importedPackage, spec *ast.TypeSpec, typeArgs []ast.Expr,
+) string {
+	params := typeParamNames(spec)
+	switch {
+	case params == nil:
+		res.fail(fmt.Errorf(
+			"type %s is not generic", res.qualifiedName(p, spec.Name.Name),
+		))
+		return spec.Name.Name
+	case len(params) != len(typeArgs):
+		res.fail(fmt.Errorf(
+			"wrong number of type arguments for %s: expected %d, got %d",
+			res.qualifiedName(p, spec.Name.Name), len(params), len(typeArgs),
+		))
+		return spec.Name.Name
+	}
+	args := make([]string, len(typeArgs))
+	subst := make(map[string]ast.Expr, len(params))
+	for i, a := range typeArgs {
+		args[i] = types.ExprString(a)
+		subst[params[i]] = a
+	}
+	key := p.path + "." + spec.Name.Name + "[" + strings.Join(args, ", ") + "]"
+	if local, ok := res.local[key]; ok {
+		return local
+	}
+	local := res.localName(p, spec.Name.Name)
+	res.local[key] = local
+	inst := &ast.TypeSpec{
+		Doc:     spec.Doc,
+		Name:    &ast.Ident{NamePos: spec.Name.NamePos},
+		Assign:  spec.Assign,
+		Type:    cloneTypeExpr(spec.Type),
+		Comment: spec.Comment,
+	}
+	res.add(p, inst, local, subst)
+	return local
+}
+
+// resolve returns the local name of the type spec of p,
+// adding it on first use.
+func (res *typeResolver) resolve(p *importedPackage, spec *ast.TypeSpec) string {
+	if spec.Assign.IsValid() {
+		return res.resolveAlias(p, spec)
+	}
+	if p == res.main {
+		if _, ok := res.r.Specs[spec.Name.Name]; !ok {
+			res.add(p, spec, spec.Name.Name, nil)
+		}
+		return spec.Name.Name
+	}
+	key := p.path + "." + spec.Name.Name
+	if local, ok := res.local[key]; ok {
+		return local
+	}
+	local := res.localName(p, spec.Name.Name)
+	res.local[key] = local
+	res.add(p, spec, local, nil)
+	return local
+}
+
+// resolveAlias returns the local name of the type denoted by the alias spec
+// of p, following chains of aliases. Aliases of types that aren't inlined,
+// such as types of the standard library, are added instead.
+func (res *typeResolver) resolveAlias(p *importedPackage, spec *ast.TypeSpec) string {
+	key := p.path + "." + spec.Name.Name
+	if local, ok := res.local[key]; ok {
+		return local
+	}
+	if i := slices.Index(res.aliases, key); i != -1 {
+		cycle := append(slices.Clone(res.aliases[i:]), key)
+		for i, c := range cycle {
+			cycle[i] = strings.TrimPrefix(c, res.main.path+".")
+		}
+		res.fail(fmt.Errorf(
+			"invalid recursive type alias: %s", strings.Join(cycle, " -> "),
+		))
+		return spec.Name.Name
+	}
+	res.aliases = append(res.aliases, key)
+	defer func() { res.aliases = res.aliases[:len(res.aliases)-1] }()
+
+	target := res.walk(p, cloneTypeExpr(spec.Type), nil)
+	if i, ok := target.(*ast.Ident); ok && res.r.Specs[i.Name] != nil {
+		res.local[key] = i.Name
+		return i.Name
+	}
+	local := res.localName(p, spec.Name.Name)
+	res.local[key] = local
+	alias := *spec
+	alias.Name = &ast.Ident{NamePos: spec.Name.NamePos, Name: local}
+	alias.Type = target
+	res.r.Specs[local] = &alias
+	res.r.Names = append(res.r.Names, local)
+	return local
+}
+
+// localName returns the name of the type name of the imported package p
+// or of an instantiation of the generic type name of p, which is the
+// original name unless taken by a type of the package of the root types
+// or another imported or instantiated type, in which case it's prefixed
+// by the name of an imported package and, if still taken,
+// suffixed by a number. Generic types and aliases don't take their names
+// since only instantiations and the types denoted by aliases are inlined.
+func (res *typeResolver) localName(p *importedPackage, name string) string {
+	taken := func(n string) bool {
+		s := findType(res.fset, res.main.pkg, n)
+		if s != nil && s.TypeParams == nil && !s.Assign.IsValid() {
+			return true
+		}
+		for _, l := range res.local {
+			if l == n {
+				return true
+			}
+		}
+		return false
+	}
+	if !taken(name) {
+		return name
+	}
+	if p == res.main {
+		local := name
+		for i := 2; taken(local); i++ {
+			local = name + strconv.Itoa(i)
+		}
+		return local
+	}
+	prefixed := p.pkg.Name + name
+	if ast.IsExported(name) {
+		r, size := utf8.DecodeRuneInString(p.pkg.Name)
+		prefixed = string(unicode.ToUpper(r)) + p.pkg.Name[size:] + name
+	}
+	local := prefixed
+	for i := 2; taken(local); i++ {
+		local = prefixed + strconv.Itoa(i)
+	}
+	return local
+}
+
+// qualifiedName returns name qualified by the name of p
+// unless p is the package of the root types.
+func (res *typeResolver) qualifiedName(p *importedPackage, name string) string {
+	if p == res.main {
+		return name
+	}
+	return p.pkg.Name + "." + name
+}
+
+// findImport returns the path of the package outside the standard library
+// imported by p under name, or an empty string if there's none.
+func (res *typeResolver) findImport(p *importedPackage, name string) string {
+	for _, k := range sortedKeys(p.pkg.Files) {
+		for _, imp := range p.pkg.Files[k].Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || isStdPackage(importPath) {
+				continue
+			}
+			if imp.Name != nil {
+				if imp.Name.Name == name {
+					return importPath
+				}
+				continue
+			}
+			if path.Base(importPath) == name {
+				return importPath
+			}
+			// The package name may differ from the last path element.
+			if i, err := res.importPackage(p, importPath); err == nil &&
+				i.pkg.Name == name {
+				return importPath
+			}
+		}
+	}
+	return ""
+}
+
+// importPackage returns the package of the module or its vendor directory
+// with the given import path, which is imported by p.
+// Returns an error if the import introduces an import cycle.
+func (res *typeResolver) importPackage(
+	p *importedPackage, importPath string,
+) (*importedPackage, error) {
+	if !slices.Contains(res.imports[p.path], importPath) {
+		res.imports[p.path] = append(res.imports[p.path], importPath)
+	}
+	if cycle := res.importCycle(importPath, p.path); cycle != nil {
+		return nil, fmt.Errorf(
+			"import cycle not allowed: %s", strings.Join(cycle, " -> "),
+		)
+	}
+	if imported, ok := res.packages[importPath]; ok {
+		return imported, nil
+	}
+
+	var dir string
+	switch {
+	case res.modRoot == "":
+		return nil, fmt.Errorf("package %q can't be located, "+
+			"the package isn't part of a module", importPath)
+	case importPath == res.modPath:
+		dir = res.modRoot
+	case strings.HasPrefix(importPath, res.modPath+"/"):
+		dir = filepath.Join(
+			res.modRoot, filepath.FromSlash(strings.TrimPrefix(importPath, res.modPath+"/")),
+		)
+	default:
+		dir = filepath.Join(res.modRoot, "vendor", filepath.FromSlash(importPath))
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("package %q is neither part of module %s "+
+				"nor vendored", importPath, res.modPath)
+		}
+	}
+	pkg, err := parseImportedPackage(res.fset, dir)
+	if err != nil {
+		return nil, fmt.Errorf("importing package %q: %w", importPath, err)
+	}
+	imported := &importedPackage{path: importPath, pkg: pkg}
+	res.packages[importPath] = imported
+	return imported, nil
+}
+
+// importCycle returns the import paths of the cycle from importPath
+// back to itself if from is reachable from importPath, otherwise nil.
+func (res *typeResolver) importCycle(importPath, from string) []string {
+	var find func(p string, visited map[string]bool) []string
+	find = func(p string, visited map[string]bool) []string {
+		if p == from {
+			return []string{p}
+		}
+		if visited[p] {
+			return nil
+		}
+		visited[p] = true
+		for _, next := range res.imports[p] {
+			if c := find(next, visited); c != nil {
+				return append([]string{p}, c...)
+			}
+		}
+		return nil
+	}
+	c := find(importPath, map[string]bool{})
+	if c == nil {
+		return nil
+	}
+	return append([]string{from}, c...)
+}
+
+// splitTypeArgs returns the generic type and the type arguments
+// of the instantiation e, or e and nil if e isn't an instantiation.
+func splitTypeArgs(e ast.Expr) (x ast.Expr, typeArgs []ast.Expr) {
+	switch t := e.(type) {
+	case *ast.IndexExpr:
+		return t.X, []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		return t.X, t.Indices
+	}
+	return e, nil
+}
+
+// typeParamNames returns the names of the type parameters of spec,
+// or nil if spec isn't generic.
+func typeParamNames(spec *ast.TypeSpec) (names []string) {
+	if spec.TypeParams == nil {
+		return nil
+	}
+	for _, f := range spec.TypeParams.List {
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+// cloneTypeExpr returns a copy of the type expression e
+// that typeResolver.walk can rewrite without affecting e.
+func cloneTypeExpr(e ast.Expr) ast.Expr {
+	switch t := e.(type) {
+	case *ast.Ident:
+		c := *t
+		return &c
+	case *ast.StarExpr:
+		c := *t
+		c.X = cloneTypeExpr(t.X)
+		return &c
+	case *ast.SelectorExpr:
+		c := *t
+		c.X = cloneTypeExpr(t.X)
+		return &c
+	case *ast.ArrayType:
+		c := *t
+		c.Elt = cloneTypeExpr(t.Elt)
+		return &c
+	case *ast.MapType:
+		c := *t
+		c.Key, c.Value = cloneTypeExpr(t.Key), cloneTypeExpr(t.Value)
+		return &c
+	case *ast.IndexExpr:
+		c := *t
+		c.X, c.Index = cloneTypeExpr(t.X), cloneTypeExpr(t.Index)
+		return &c
+	case *ast.IndexListExpr:
+		c := *t
+		c.X = cloneTypeExpr(t.X)
+		c.Indices = make([]ast.Expr, len(t.Indices))
+		for i, x := range t.Indices {
+			c.Indices[i] = cloneTypeExpr(x)
+		}
+		return &c
+	case *ast.StructType:
+		c := *t
+		fields := *t.Fields
+		fields.List = make([]*ast.Field, len(t.Fields.List))
+		for i, f := range t.Fields.List {
+			fc := *f
+			fc.Type = cloneTypeExpr(f.Type)
+			fields.List[i] = &fc
+		}
+		c.Fields = &fields
+		return &c
+	}
+	return e
+}
+
+// parseImportedPackage parses the package in dir excluding test files.
+func parseImportedPackage(fset *token.FileSet, dir string) (*ast.Package, error) {
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.AllErrors)
+	if err != nil {
+		return nil, fmt.Errorf("parsing package: %w", err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected 1 package in %s, found %d", dir, len(pkgs))
+	}
+	for _, pkg := range pkgs {
+		return pkg, nil
+	}
+	return nil, nil
+}
+
+// findModule returns the root directory and the path of the module
+// dir is part of, or empty strings if there's no go.mod file in dir
+// or any of its parents.
+func findModule(dir string) (root, modulePath string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		if p, err := readModulePath(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module path declared by the go.mod file.
+func readModulePath(goModFile string) (string, error) {
+	f, err := os.Open(goModFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			if p, err := strconv.Unquote(fields[1]); err == nil {
+				return p, nil
+			}
+			return fields[1], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("missing module directive")
+}
@@ -0,0 +1,50 @@
+package valfile
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// lintTags checks the marshaling tags of all exported struct types
+// of the package selected by p for inputs of format p.Format.
+func lintTags(p Params) (errs []error) {
+	fset := token.NewFileSet()
+	pkg, err := parsePackage(fset, p.PackageDir)
+	if err != nil {
+		return []error{err}
+	}
+
+	expectMarshalingTag := p.Format.MarshalingTag()
+	if p.TagFallback != nil {
+		if err := applyTagFallback(pkg, expectMarshalingTag, p.TagFallback); err != nil {
+			return []error{err}
+		}
+	}
+	if expectMarshalingTag == "env" {
+		if err := applyEnvPrefixes(fset, pkg); err != nil {
+			return []error{err}
+		}
+	}
+
+	for _, k := range sortedKeys(pkg.Files) {
+		for _, decl := range pkg.Files[k].Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				t := spec.(*ast.TypeSpec)
+				if !t.Name.IsExported() {
+					continue
+				}
+				errs = append(errs, checkMarshalingTags(
+					fset, t, expectMarshalingTag, p.TagStyle, p.RequireTagOnAll,
+				)...)
+				if p.ValidateTags || p.RequireValidate {
+					errs = append(errs, checkValidateTags(fset, t, p.RequireValidate)...)
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -1,9 +1,13 @@
-package main
+package valfile
 
 import (
 	"archive/zip"
 	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,18 +15,22 @@ import (
 	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"io"
+	"io/fs"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/fatih/structtag"
-	"github.com/google/go-jsonnet"
-	"github.com/joho/godotenv"
 )
 
 //go:embed tmpl_main_env.go.tmpl
@@ -37,12 +45,27 @@ var tmplMainJSON string
 //go:embed tmpl_main_yaml.go.tmpl
 var tmplMainYAML string
 
+//go:embed tmpl_main_yaml_kinds.go.tmpl
+var tmplMainYAMLKinds string
+
 //go:embed tmpl_main_hcl.go.tmpl
 var tmplMainHCL string
 
+//go:embed tmpl_main_xml.go.tmpl
+var tmplMainXML string
+
+//go:embed tmpl_main_ini.go.tmpl
+var tmplMainINI string
+
 //go:embed tmpl_validate.go.tmpl
 var tmplSrcValidate string
 
+//go:embed tmpl_checks.go.tmpl
+var tmplSrcChecks string
+
+//go:embed tmpl_yaml.go.tmpl
+var tmplSrcYAML string
+
 //go:embed vendor_env.zip
 var vendorENV []byte
 
@@ -58,6 +81,9 @@ var vendorYAML []byte
 //go:embed vendor_hcl.zip
 var vendorHCL []byte
 
+//go:embed vendor_ini.zip
+var vendorINI []byte
+
 //go:embed tmpl_gomod_env.txt
 var gomodENV []byte
 
@@ -73,6 +99,9 @@ var gomodYAML []byte
 //go:embed tmpl_gomod_hcl.txt
 var gomodHCL []byte
 
+//go:embed tmpl_gomod_ini.txt
+var gomodINI []byte
+
 //go:embed tmpl_gosum_env.txt
 var gosumENV []byte
 
@@ -88,36 +117,167 @@ var gosumYAML []byte
 //go:embed tmpl_gosum_hcl.txt
 var gosumHCL []byte
 
+//go:embed tmpl_gosum_ini.txt
+var gosumINI []byte
+
 var (
-	tmplValidate = template.Must(template.New("validate").Parse(tmplSrcValidate))
-	tmplTOML     = withTmpl("main_toml", tmplMainTOML, tmplValidate)
-	tmplJSON     = withTmpl("main_json", tmplMainJSON, tmplValidate)
-	tmplYAML     = withTmpl("main_yaml", tmplMainYAML, tmplValidate)
-	tmplHCL      = withTmpl("main_hcl", tmplMainHCL, tmplValidate)
-	tmplENV      = withTmpl("main_env", tmplMainENV, tmplValidate)
+	tmplValidate  = template.Must(template.New("validate").Parse(tmplSrcValidate))
+	tmplChecks    = template.Must(template.New("checks").Parse(tmplSrcChecks))
+	tmplYAMLCheck = template.Must(template.New("yaml").Parse(tmplSrcYAML))
+	tmplTOML      = withTmpl("main_toml", tmplMainTOML, tmplValidate, tmplChecks)
+	tmplJSON      = withTmpl("main_json", tmplMainJSON, tmplValidate, tmplChecks)
+	tmplYAML      = withTmpl(
+		"main_yaml", tmplMainYAML, tmplValidate, tmplChecks, tmplYAMLCheck,
+	)
+	tmplYAMLKinds = withTmpl(
+		"main_yaml_kinds", tmplMainYAMLKinds, tmplValidate, tmplChecks, tmplYAMLCheck,
+	)
+	tmplHCL = withTmpl("main_hcl", tmplMainHCL, tmplValidate, tmplChecks)
+	tmplENV = withTmpl("main_env", tmplMainENV, tmplValidate, tmplChecks)
+	tmplXML = withTmpl("main_xml", tmplMainXML, tmplValidate, tmplChecks)
+	tmplINI = withTmpl("main_ini", tmplMainINI, tmplValidate, tmplChecks)
 )
 
 func withTmpl(name, src string, t ...*template.Template) *template.Template {
 	tmpl := template.Must(template.New(name).Parse(src))
 	for _, t := range t {
-		if _, err := tmpl.New(t.Name()).Parse(tmplSrcValidate); err != nil {
+		if _, err := tmpl.AddParseTree(t.Name(), t.Tree); err != nil {
 			panic(err)
 		}
 	}
 	return tmpl
 }
 
-const StdoutErrPrefix = "VALFILE: "
+// DefaultMaxInputSize is the default maximum size of an input file in bytes.
+const DefaultMaxInputSize = 10 << 20
+
+const (
+	StdoutErrPrefix  = "VALFILE: "
+	StdoutWarnPrefix = "VALFILE_WARN: "
+
+	// StdoutDefaultPrefix prefixes optional fields falling back to their
+	// default value, followed by the tab-separated path, key and default.
+	StdoutDefaultPrefix = "VALFILE_DEFAULT: "
+
+	// StdoutEffectivePrefix prefixes the quoted effective input.
+	StdoutEffectivePrefix = "VALFILE_EFFECTIVE: "
+)
+
+// RunCLI runs valfile with the command line arguments args,
+// the first of which is the program name, and returns the exit code.
+func RunCLI(args []string) (exitCode int) {
+	p, err := parseCLIParameters(args)
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if p.Silent {
+		// The exit code is the only output, including for invalid parameters.
+		stdout, stderr = io.Discard, io.Discard
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, err.Error())
+		return ExitUsage
+	}
+	if p.ExplainType {
+		if errs := explainType(stdout, p); errs != nil {
+			for _, err := range errs {
+				fmt.Fprintln(stdout, err.Error())
+			}
+			return Report{Errs: errs}.ExitCode()
+		}
+		return ExitOK
+	}
+	if p.EmitSchema {
+		if errs := emitSchema(stdout, p); errs != nil {
+			for _, err := range errs {
+				fmt.Fprintln(stdout, err.Error())
+			}
+			return Report{Errs: errs}.ExitCode()
+		}
+		return ExitOK
+	}
+	var outputTemplate *template.Template
+	if p.OutputTemplate != "" {
+		// Parsed before validating to not waste a run on a broken template.
+		if outputTemplate, err = parseOutputTemplate(p.OutputTemplate); err != nil {
+			fmt.Fprintln(stdout, err.Error())
+			return ExitUsage
+		}
+	}
+	if p.Watch {
+		ctx, stop := signal.NotifyContext(
+			context.Background(), os.Interrupt, syscall.SIGTERM,
+		)
+		defer stop()
+		err := watch(ctx, stdout, p, func() {
+			executeAndReport(stdout, stderr, p, outputTemplate)
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return ExitFailure
+		}
+		return ExitOK
+	}
+	return executeAndReport(stdout, stderr, p, outputTemplate)
+}
+
+// executeAndReport validates all inputs selected by p, writes the report
+// to stdout and returns the exit code. If stdout carries the effective inputs
+// or the defaults report instead, the report is written to stderr such that
+// they can be redirected to a file.
+func executeAndReport(
+	stdout, stderr io.Writer, p Params, outputTemplate *template.Template,
+) (exitCode int) {
+	r := execute(p, os.TempDir, os.Environ)
+	for _, dir := range keptTempDirs(r) {
+		fmt.Fprintf(stderr, "keeping temporary directory %s\n", dir)
+	}
+	report := stdout
+	if p.PrintEffective || p.ReportDefaults != "" && p.ReportDefaultsFile == "" {
+		report = stderr
+	}
+	if p.ReportDefaults != "" {
+		if err := writeDefaultsReportFile(stdout, p.ReportDefaultsFile, r); err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return ExitFailure
+		}
+	}
+	if p.PrintEffective {
+		if err := writeEffective(stdout, r); err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return ExitFailure
+		}
+	}
+	var err error
+	if outputTemplate != nil {
+		err = writeTemplate(report, outputTemplate, r)
+	} else {
+		err = writeReport(report, p.Output, r)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return ExitFailure
+	}
+	return r.ExitCode()
+}
 
-func main() {
-	if errs := run(os.Args, os.TempDir, os.Environ); len(errs) > 0 {
-		for _, err := range errs {
-			fmt.Fprintln(os.Stdout, err.Error())
+// keptTempDirs returns the temporary directories of the generated programs
+// of r kept due to Params.KeepTemp.
+func keptTempDirs(r Report) (dirs []string) {
+	errs := slices.Clone(r.Errs)
+	for _, res := range r.Results {
+		errs = append(errs, res.Errs...)
+	}
+	for _, err := range errs {
+		if d := asDiagnostic(err); d.Code == CodeTempDir {
+			dirs = append(dirs, d.Message)
 		}
-		os.Exit(1)
 	}
+	return dirs
 }
 
+// run executes valfile with the CLI arguments and returns all errors.
 func run(
 	args []string,
 	makeTmpDir func() string,
@@ -127,195 +287,466 @@ func run(
 	if err != nil {
 		return []error{err}
 	}
+	return execute(p, makeTmpDir, envVars).Errors()
+}
 
-	inputType := InputTypeENV
-	if !p.InputEnv {
-		var err error
-		inputType, err = getFileFormat(p.InputFile)
-		if err != nil {
-			return []error{err}
+// execute validates all inputs selected by p.
+// With Params.Strict all warnings are reported as errors.
+func execute(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) Report {
+	r := executeParams(p, makeTmpDir, envVars)
+	if p.Strict {
+		r.Errs = warningsAsErrors(r.Errs)
+		for i := range r.Results {
+			r.Results[i].Errs = warningsAsErrors(r.Results[i].Errs)
 		}
 	}
+	return r
+}
 
-	fset := token.NewFileSet()
-
-	pkg, err := parsePackage(fset, p.PackageDir)
-	if err != nil {
-		return []error{err}
+// executeParams validates all inputs selected by p.
+func executeParams(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) Report {
+	switch {
+	case p.LintTags:
+		return Report{Results: []Result{{
+			Input: p.PackageDir,
+			Errs:  lintTags(p),
+		}}}
+	case p.CompileCheck:
+		e := NewEngine(p, makeTmpDir)
+		defer e.Close()
+		return Report{Results: []Result{{
+			Input: p.PackageDir,
+			Type:  resultType(p),
+			Errs:  e.CompileCheck(p.Format),
+		}}}
 	}
-
-	rootType := findType(fset, pkg, p.TypeName)
-	if rootType == nil {
-		return []error{
-			fmt.Errorf("type %s not found in package %s\n", p.TypeName, pkg.Name),
+	var r Report
+	switch {
+	case p.ConfigFile != "":
+		r = executeConfig(p, makeTmpDir, envVars)
+	case p.InputDir != "":
+		r = executeInputDir(p, makeTmpDir, envVars)
+	case p.InputFiles != nil:
+		r = executeInputFiles(p, makeTmpDir, envVars)
+	default:
+		r = Report{Results: []Result{{
+			Input: inputName(p),
+			Type:  resultType(p),
+			Errs:  validate(p, makeTmpDir, envVars),
+		}}}
+	}
+	if p.ReportUnused {
+		for _, path := range findUnusedFields(r.Results) {
+			r.Errs = append(r.Errs, &Diagnostic{
+				Severity: SeverityWarning,
+				Code:     CodeUnused,
+				Message:  path + ": not set by any input",
+			})
 		}
 	}
+	return r
+}
+
+// resultType returns the Result.Type of inputs validated with p.
+func resultType(p Params) string {
+	if p.AnyTypes != nil || p.KindTypes != nil || p.FieldTypes != nil {
+		return ""
+	}
+	return p.PackageDir + "." + p.TypeName
+}
 
-	typeStr, err := renderGoType(rootType, fset)
+// executeConfig validates all targets declared in the config file.
+func executeConfig(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) (r Report) {
+	c, err := loadConfig(p.ConfigFile)
 	if err != nil {
-		return []error{fmt.Errorf("rendering go type: %w", err)}
+		return Report{Errs: []error{err}}
 	}
-	typeDefinitions := []string{typeStr}
-	typeSpecs := map[string]*ast.TypeSpec{
-		p.TypeName: rootType,
+	if err := checkTargetFilesExist(c.Targets); err != nil {
+		return Report{Errs: []error{err}}
 	}
-
-	traverseTypeIdents(fset, pkg, rootType.Type, func(i *ast.Ident) bool {
-		if isTypePrimitive(i.Name) {
-			return false
+	if p.WarnExtraFiles {
+		uncovered, err := findUncoveredFiles(p.ConfigFile, c.Targets)
+		if err != nil {
+			return Report{Errs: []error{err}}
 		}
-		t := findType(fset, pkg, i.Name)
-		if t == nil {
-			errs = append(errs, fmt.Errorf("undefined type: %s", i.Name))
-			return true
+		for _, f := range uncovered {
+			r.Errs = append(r.Errs, &Diagnostic{
+				Severity: SeverityWarning,
+				Code:     CodeUncovered,
+				Message:  f + ": not validated by any target",
+			})
 		}
-		if _, ok := typeSpecs[t.Name.Name]; ok {
-			return false
+	}
+	for _, t := range c.Targets {
+		tp := p
+		tp.ConfigFile = ""
+		tp.PackageDir, tp.TypeName, tp.InputEnv = t.Package, t.Type, t.Env
+		if t.Env {
+			r.Results = append(r.Results, Result{
+				Input: inputName(tp),
+				Type:  resultType(tp),
+				Errs:  validate(tp, makeTmpDir, envVars),
+			})
+			continue
 		}
-		r, err := renderGoType(t, fset)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("rendering go type: %w", err))
-			return true
+		e := NewEngine(tp, makeTmpDir)
+		for _, f := range t.Files {
+			tp.InputFile = f
+			r.Results = append(r.Results, Result{
+				Input: inputName(tp),
+				Type:  resultType(tp),
+				Errs:  validateWith(e, tp, envVars),
+			})
 		}
-		typeSpecs[t.Name.Name] = t
-		typeDefinitions = append(typeDefinitions, r)
-		return false
-	})
-	if errs != nil {
-		return errs
+		e.Close()
 	}
+	return r
+}
 
-	fileName := filepath.Base(p.InputFile)
-
-	// Write format-specific executable to temporary file
-	var source, goMod, goSum, vendorArchive []byte
-	var expectMarshalingTag string
-	switch inputType {
-	case InputTypeENV:
-		m := envToMap(envVars())
-		source = mustRenderSrcEnv(typeDefinitions, p.TypeName, m)
-		goMod, goSum, vendorArchive = gomodENV, gosumENV, vendorENV
-		expectMarshalingTag = "env"
-	case InputTypeDOTENV:
-		f, err := os.OpenFile(p.InputFile, os.O_RDONLY, 0o644)
-		if err != nil {
-			return []error{fmt.Errorf("reading input file: %w", err)}
-		}
-		m, err := godotenv.Parse(f)
-		if err != nil {
-			return []error{fmt.Errorf("parsing dotenv file: %w", err)}
+// executeInputDir validates all files in the input directory and its
+// subdirectories against the types selected by the type mappings.
+// Files not matching any pattern are ignored.
+func executeInputDir(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) (r Report) {
+	engines := map[string]*Engine{}
+	defer func() {
+		for _, e := range engines {
+			e.Close()
 		}
-		source = mustRenderSrcEnv(typeDefinitions, p.TypeName, m)
-		goMod, goSum, vendorArchive = gomodENV, gosumENV, vendorENV
-		expectMarshalingTag = "env"
-	case InputTypeTOML:
-		inputFileContents, err := os.ReadFile(p.InputFile)
-		if err != nil {
-			return []error{fmt.Errorf("reading input file: %w", err)}
+	}()
+	err := filepath.WalkDir(p.InputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
 		}
-		source = mustRenderSrc(
-			typeDefinitions, p.TypeName, string(inputFileContents), fileName, tmplTOML,
-		)
-		goMod, goSum, vendorArchive = gomodTOML, gosumTOML, vendorTOML
-		expectMarshalingTag = "toml"
-	case InputTypeJSON:
-		inputFileContents, err := os.ReadFile(p.InputFile)
-		if err != nil {
-			return []error{fmt.Errorf("reading input file: %w", err)}
+		typeName := matchTypeMapping(p.TypeMappings, d.Name())
+		if typeName == "" {
+			return nil
 		}
-		source = mustRenderSrc(
-			typeDefinitions, p.TypeName, string(inputFileContents), fileName, tmplJSON,
-		)
-		goMod, goSum, vendorArchive = gomodJSON, gosumJSON, vendorJSON
-		expectMarshalingTag = "json"
-	case InputTypeYAML:
-		inputFileContents, err := os.ReadFile(p.InputFile)
-		if err != nil {
-			return []error{fmt.Errorf("reading input file: %w", err)}
+		tp := p
+		tp.InputDir, tp.TypeMappings = "", nil
+		tp.TypeName, tp.InputFile = typeName, path
+		e, ok := engines[typeName]
+		if !ok {
+			e = NewEngine(tp, makeTmpDir)
+			engines[typeName] = e
 		}
-		source = mustRenderSrc(
-			typeDefinitions, p.TypeName, string(inputFileContents), fileName, tmplYAML,
-		)
-		goMod, goSum, vendorArchive = gomodYAML, gosumYAML, vendorYAML
-		expectMarshalingTag = "yaml"
-	case InputTypeJSONNET:
-		vm := jsonnet.MakeVM()
-		rendered, err := vm.EvaluateFile(p.InputFile)
-		if err != nil {
-			return []error{fmt.Errorf("evaluating Jsonnet: %w", err)}
+		r.Results = append(r.Results, Result{
+			Input: inputName(tp),
+			Type:  resultType(tp),
+			Errs:  validateWith(e, tp, envVars),
+		})
+		return nil
+	})
+	if err != nil {
+		r.Errs = append(r.Errs, fmt.Errorf("reading input directory: %w", err))
+	} else if r.Results == nil {
+		r.Errs = append(r.Errs, fmt.Errorf(
+			"no files in %s match any of the type mappings", p.InputDir,
+		))
+	}
+	return r
+}
+
+// executeInputFiles validates each of the input files against the type
+// selected by p. The package is parsed once per input format and the
+// marshaling tags are checked according to the format of each file.
+func executeInputFiles(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) (r Report) {
+	e := NewEngine(p, makeTmpDir)
+	defer e.Close()
+	for _, f := range p.InputFiles {
+		fp := p
+		fp.InputFile, fp.InputFiles = f, nil
+		var errs []error
+		if p.AnyTypes != nil {
+			errs = validate(fp, makeTmpDir, envVars)
+		} else {
+			errs = validateWith(e, fp, envVars)
 		}
-		source = mustRenderSrc(
-			typeDefinitions, p.TypeName, rendered, fileName, tmplJSON,
-		)
-		goMod, goSum, vendorArchive = gomodJSON, gosumJSON, vendorJSON
-		expectMarshalingTag = "json"
-	case InputTypeHCL:
-		inputFileContents, err := os.ReadFile(p.InputFile)
-		if err != nil {
-			return []error{fmt.Errorf("reading input file: %w", err)}
+		r.Results = append(r.Results, Result{
+			Input: inputName(fp),
+			Type:  resultType(fp),
+			Errs:  errs,
+		})
+	}
+	return r
+}
+
+// matchTypeMapping returns the type name of the first mapping
+// whose pattern matches fileName, or an empty string if none matches.
+func matchTypeMapping(mappings []TypeMapping, fileName string) string {
+	for _, m := range mappings {
+		if ok, _ := filepath.Match(m.Pattern, fileName); ok {
+			return m.TypeName
 		}
-		source = mustRenderSrc(
-			typeDefinitions, p.TypeName, string(inputFileContents), fileName, tmplHCL,
-		)
-		goMod, goSum, vendorArchive = gomodHCL, gosumHCL, vendorHCL
-		expectMarshalingTag = "hcl"
 	}
+	return ""
+}
 
-	if !p.NoTagCheck {
-		for _, k := range sortedKeys(typeSpecs) {
-			t := typeSpecs[k]
-			if err := checkMarshalingTags(t, expectMarshalingTag); len(err) > 0 {
-				errs = append(errs, err...)
-			}
+// inputName returns the name of the input selected by p.
+func inputName(p Params) string {
+	switch {
+	case p.InputEnv:
+		return "env"
+	case p.Overrides != nil:
+		return "set"
+	case p.Stdin:
+		return "stdin"
+	case p.MergePatch != "":
+		return p.MergePatch
+	case p.Archive != "":
+		return p.Archive + ":" + p.ArchiveEntry
+	}
+	return p.InputFile
+}
+
+// inputFileName returns the name of the input file selected by p,
+// which determines the input format.
+func inputFileName(p Params) string {
+	if p.Archive != "" {
+		return p.ArchiveEntry
+	}
+	return p.InputFile
+}
+
+// readCompressedInputFile reads the input file selected by p as is,
+// gzip-compressed files aren't decompressed.
+// Files larger than p.MaxInputSize aren't read.
+func readCompressedInputFile(p Params) ([]byte, error) {
+	if p.Archive != "" {
+		b, err := readArchiveEntry(p.Archive, p.ArchiveEntry)
+		if err != nil {
+			return nil, err
 		}
-		if errs != nil {
-			return errs
+		if err := checkInputSize(p, int64(len(b))); err != nil {
+			return nil, err
 		}
+		return b, nil
+	}
+	if isURL(p.InputFile) {
+		return fetchInput(p)
+	}
+	fi, err := os.Stat(p.InputFile)
+	if err != nil {
+		return nil, err
 	}
+	if err := checkInputSize(p, fi.Size()); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p.InputFile)
+}
+
+// stdin is the standard input read by -stdin.
+var stdin io.Reader = os.Stdin
+
+// userCacheDir returns the user cache directory compiled programs
+// are cached in unless -no-cache is set.
+var userCacheDir = os.UserCacheDir
 
-	tempDir, err := os.MkdirTemp(makeTmpDir(), "valfile-*")
+// readStdin reads the input from stdin.
+// Inputs larger than p.MaxInputSize aren't read entirely.
+func readStdin(p Params) ([]byte, error) {
+	r := stdin
+	if p.MaxInputSize > 0 {
+		r = io.LimitReader(r, p.MaxInputSize+1)
+	}
+	b, err := io.ReadAll(r)
 	if err != nil {
-		return []error{fmt.Errorf("creating temporary directory: %w", err)}
+		return nil, fmt.Errorf("reading standard input: %w", err)
+	}
+	if err := checkInputSize(p, int64(len(b))); err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) < 1 {
+		return nil, errors.New("standard input is empty")
+	}
+	return b, nil
+}
+
+// checkInputSize returns an error if size exceeds p.MaxInputSize.
+func checkInputSize(p Params, size int64) error {
+	if p.MaxInputSize > 0 && size > p.MaxInputSize {
+		return fmt.Errorf("input size %d bytes exceeds the maximum of %d bytes, "+
+			"see -max-input-size", size, p.MaxInputSize)
+	}
+	return nil
+}
+
+// validate validates a single input against the type selected by p.
+func validate(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) (errs []error) {
+	if p.AnyTypes != nil {
+		return validateAny(p, makeTmpDir, envVars)
 	}
-	defer os.RemoveAll(tempDir)
+	e := NewEngine(p, makeTmpDir)
+	defer e.Close()
+	return validateWith(e, p, envVars)
+}
 
-	{
-		p := filepath.Join(tempDir, "main.go")
-		if err = os.WriteFile(p, source, 0o644); err != nil {
-			return []error{fmt.Errorf("writing %s: %w", p, err)}
+// validateAny validates the input selected by p against each of the types
+// p.AnyTypes in order and stops at the first type the input matches.
+// If the input matches none of them, the errors of all types are returned
+// prefixed by the type name.
+func validateAny(
+	p Params,
+	makeTmpDir func() string,
+	envVars func() []string,
+) []error {
+	errs := []error{&Diagnostic{
+		Code: CodeInvalid,
+		Message: "input matches none of the types: " +
+			strings.Join(p.AnyTypes, ", "),
+	}}
+	for _, t := range p.AnyTypes {
+		tp := p
+		tp.TypeName, tp.AnyTypes = t, nil
+		typeErrs := validate(tp, makeTmpDir, envVars)
+		if !(Result{Errs: typeErrs}).Failed() {
+			return typeErrs
+		}
+		for _, err := range typeErrs {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
 		}
 	}
-	{
-		p := filepath.Join(tempDir, "go.mod")
-		if err = os.WriteFile(p, goMod, 0o644); err != nil {
-			return []error{fmt.Errorf("writing %s: %w", p, err)}
+	return errs
+}
+
+// validateWith validates the input selected by p using e.
+func validateWith(e *Engine, p Params, envVars func() []string) []error {
+	if p.Overrides != nil {
+		data, err := encodeOverrides(p.Format, p.Overrides)
+		if err != nil {
+			return []error{err}
 		}
+		return e.validateFile(p.Format, "set"+formatExtension(p.Format), data)
 	}
-	{
-		p := filepath.Join(tempDir, "go.sum")
-		if err = os.WriteFile(p, goSum, 0o644); err != nil {
-			return []error{fmt.Errorf("writing %s: %w", p, err)}
+	if p.Stdin {
+		data, err := readStdin(p)
+		if err != nil {
+			return []error{err}
 		}
+		return e.validateFile(p.Format, "stdin"+formatExtension(p.Format), data)
+	}
+	if p.MergePatch != "" {
+		data, err := readMergePatched(p)
+		if err != nil {
+			return []error{err}
+		}
+		return e.validateFile(InputTypeJSON, p.MergeBase, data)
 	}
 
-	if err = unzipArchive(vendorArchive, tempDir); err != nil {
-		return []error{fmt.Errorf("unzipping vendor directory: %w", err)}
+	inputType := InputTypeENV
+	var inputFileContents []byte
+	if !p.InputEnv {
+		var err error
+		inputType, err = getFileFormat(inputFileName(p))
+		if err != nil {
+			return withCode(CodeUsage, err)
+		}
+		if inputFileContents, err = readCompressedInputFile(p); err != nil {
+			return []error{fmt.Errorf("reading input file: %w", err)}
+		}
+		if p.ExpectSHA256 != "" {
+			// Checksums of published artifacts are those of the compressed file.
+			sum := sha256.Sum256(inputFileContents)
+			if actual := hex.EncodeToString(sum[:]); actual != p.ExpectSHA256 {
+				return []error{fmt.Errorf(
+					"input SHA-256 checksum mismatch: expected %s, got %s",
+					p.ExpectSHA256, actual,
+				)}
+			}
+		}
+		if isGzip(inputFileName(p)) {
+			if inputFileContents, err = gunzipInput(p, inputFileContents); err != nil {
+				return []error{fmt.Errorf("reading input file: %w", err)}
+			}
+		}
 	}
 
-	// Compile and run the executable
-	cmd := exec.Command("go", "run", ".")
-	cmd.Dir = tempDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return []error{err}
+	if inputType == InputTypeENV {
+		return e.validateInput(inputType, "", envToMap(envVars(), p.EnvPrefix))
 	}
-	output = bytes.TrimRight(output, "\n")
+	return e.validateFile(inputType, inputFileName(p), inputFileContents)
+}
 
-	if bytes.HasPrefix(output, []byte(StdoutErrPrefix)) {
-		msg := output[len(StdoutErrPrefix):]
-		return []error{errors.New(string(msg))}
+// parseProgramOutput returns the errors and warnings reported by the generated
+// program. Each error starts on a new line with StdoutErrPrefix and each warning
+// with StdoutWarnPrefix, following lines without a prefix belong to
+// the preceding message. Lines starting with StdoutDefaultPrefix are returned
+// as diagnostics of severity SeverityInfo, and so is the effective input
+// following StdoutEffectivePrefix.
+func parseProgramOutput(output []byte) (errs []error) {
+	output = bytes.TrimRight(output, "\n")
+	var msg []string
+	var severity Severity
+	flush := func() {
+		if msg != nil {
+			d := newInvalidInputDiagnostic(strings.Join(msg, "\n"))
+			d.Severity = severity
+			errs = append(errs, d)
+		}
+		msg = nil
 	}
-	return nil
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, StdoutErrPrefix):
+			flush()
+			msg, severity = []string{line[len(StdoutErrPrefix):]}, SeverityError
+		case strings.HasPrefix(line, StdoutWarnPrefix):
+			flush()
+			msg, severity = []string{line[len(StdoutWarnPrefix):]}, SeverityWarning
+		case strings.HasPrefix(line, StdoutDefaultPrefix):
+			flush()
+			f := strings.SplitN(line[len(StdoutDefaultPrefix):], "\t", 3)
+			if len(f) < 3 {
+				continue
+			}
+			errs = append(errs, &Diagnostic{
+				Severity: SeverityInfo,
+				Code:     CodeDefault,
+				Message:  fmt.Sprintf("%s: not set, defaults to %s", f[0], f[2]),
+				Defaulted: &DefaultedField{
+					Path: f[0], Key: f[1], Default: f[2],
+				},
+			})
+		case strings.HasPrefix(line, StdoutEffectivePrefix):
+			flush()
+			s, err := strconv.Unquote(line[len(StdoutEffectivePrefix):])
+			if err != nil {
+				continue
+			}
+			errs = append(errs, &Diagnostic{
+				Severity: SeverityInfo,
+				Code:     CodeEffective,
+				Message:  s,
+			})
+		case msg != nil:
+			msg = append(msg, line)
+		}
+	}
+	flush()
+	return errs
 }
 
 type Params struct {
@@ -323,84 +754,1035 @@ type Params struct {
 	TypeName   string
 	InputFile  string
 	InputEnv   bool
+
+	// Stdin reads the input of format Format from the standard input.
+	Stdin bool
+
 	NoTagCheck bool
+	ConfigFile string
+
+	FieldsRequiredByDefault bool
+
+	// KindTypes maps kinds of YAML documents to type names.
+	KindTypes map[string]string
+
+	// FieldTypes maps keys of the input to the names of the types
+	// their values are validated against, see addFieldsType.
+	FieldTypes map[string]string
+
+	// JsonnetExtStr and JsonnetExtCode map the names of external variables
+	// of Jsonnet inputs to their string values and Jsonnet expressions.
+	JsonnetExtStr  map[string]string
+	JsonnetExtCode map[string]string
+
+	// JsonnetPaths are the library search paths of Jsonnet imports.
+	JsonnetPaths []string
+
+	// TagFallback lists the tags, in order of priority, that are used
+	// for fields lacking the marshaling tag of the input format.
+	TagFallback []string
+
+	// Output is the output format.
+	Output string
+
+	// OutputTemplate is the path of a text/template file
+	// the report is rendered with instead of the output format.
+	OutputTemplate string
+
+	// PrintEffective prints the decoded value of every valid input.
+	PrintEffective bool
+
+	// EffectiveFormat is the format of the printed effective input,
+	// either "json" or the input format, which is the default.
+	EffectiveFormat string
+
+	// ReportDefaults is the format of the report of optional fields
+	// falling back to their default values, no report if empty.
+	ReportDefaults string
+
+	// ReportDefaultsFile is the path of the file the defaults report
+	// is written to, stdout if empty.
+	ReportDefaultsFile string
+
+	// ReportUnused reports a warning for every field
+	// that isn't set by any input of its type.
+	ReportUnused bool
+
+	// WarnZero reports a warning for every field
+	// that is the zero value after decoding.
+	WarnZero bool
+
+	// Strict reports all warnings as errors.
+	Strict bool
+
+	// SOPS validates the structure of SOPS encrypted files
+	// ignoring their metadata and encrypted values.
+	SOPS bool
+
+	// StrictStrings warns about unquoted YAML values of string fields
+	// that YAML 1.2 or 1.1 resolve to another type, such as no.
+	StrictStrings bool
+
+	// MultiDoc validates every document of YAML inputs
+	// against the type instead of only the first.
+	MultiDoc bool
+
+	// AnyTypes are the names of the types the input must match any of.
+	// TypeName is the first of them.
+	AnyTypes []string
+
+	// Enums reports values of integer types with declared constants
+	// that aren't one of them.
+	Enums bool
+
+	// Workspace is the persistent directory the modules of the generated
+	// programs are set up in, such that they're reused across runs.
+	// Temporary directories are used if empty. Programs that weren't used
+	// for 30 days are removed when a new one is set up.
+	Workspace string
+
+	// CacheDir is the directory compiled programs are cached in, such that
+	// runs of the same program don't compile it again.
+	// Programs are compiled on every run if empty. Programs that weren't
+	// used for 30 days are removed when a new one is cached.
+	CacheDir string
+
+	// ValidateTags checks the syntax of validate tags along with
+	// the marshaling tags.
+	ValidateTags bool
+
+	// RequireValidate reports fields lacking a validate tag,
+	// implies ValidateTags.
+	RequireValidate bool
+
+	// KeepTemp keeps the temporary directories of the generated programs
+	// instead of removing them and prints their paths to stderr.
+	KeepTemp bool
+
+	// InputFiles are the input files if -f is repeated or is a glob pattern
+	// matching several files, each validated according to its own format.
+	// InputFile is the first of them.
+	InputFiles []string
+
+	// Archive is the path to an archive containing the input file
+	// ArchiveEntry.
+	Archive      string
+	ArchiveEntry string
+
+	// InputDir is the path to a directory of input files that are validated
+	// against the types selected by TypeMappings.
+	InputDir     string
+	TypeMappings []TypeMapping
+
+	// ExplainType prints the resolved types instead of validating any input.
+	ExplainType bool
+
+	// EmitSchema prints a JSON Schema of the type instead of validating any input.
+	EmitSchema bool
+
+	// GoCommand is the path of the go command compiling the generated
+	// programs, defaults to go found in PATH.
+	GoCommand string
+
+	// BaseDir is the directory relative paths of fields tagged
+	// valfile:"file" or valfile:"dir" are resolved against.
+	// Defaults to the directory of the input file.
+	BaseDir string
+
+	// MaxInputSize is the maximum size of an input file in bytes,
+	// 0 means unlimited.
+	MaxInputSize int64
+
+	// Timeout is the timeout of the request if InputFile is an HTTP(S) URL,
+	// 0 means no timeout.
+	Timeout time.Duration
+
+	// Overrides are key-value pairs, such as "server.port=8080", the input
+	// of format Format is constructed from instead of reading a file.
+	Overrides []Override
+
+	// ExpectSHA256 is the hex-encoded SHA-256 checksum
+	// the contents of the input file must match.
+	ExpectSHA256 string
+
+	// MergePatch is the path to a JSON Merge Patch applied to
+	// the JSON file MergeBase, the result of which is validated.
+	MergePatch string
+	MergeBase  string
+
+	// FailOnEmpty reports empty inputs and inputs decoding to a zero value.
+	FailOnEmpty bool
+
+	// EnvExact requires environment variables to match the fields of the type
+	// exactly, every field must be set and every variable must be consumed.
+	EnvExact bool
+
+	// EnvStrict reports environment variables that aren't consumed
+	// by any field, requires EnvPrefix for InputEnv.
+	EnvStrict bool
+
+	// EnvCaseInsensitive matches the names of environment variables
+	// against the env tags of fields case-insensitively.
+	EnvCaseInsensitive bool
+
+	// EnvPrefix restricts the environment variables used as input to those
+	// starting with the prefix, which is stripped from their names.
+	EnvPrefix string
+
+	// Watch validates again whenever an input or the Go package changes
+	// until interrupted.
+	Watch bool
+
+	// Silent prints nothing, the exit code is the only output.
+	Silent bool
+
+	// EnvrcStrict rejects statements of .envrc files
+	// other than variable assignments instead of ignoring them.
+	EnvrcStrict bool
+
+	// LintTags checks the marshaling tags of all exported struct types
+	// of the package for inputs of format Format instead of validating any input.
+	LintTags bool
+	Format   InputType
+
+	// CompileCheck renders and compiles the generated program for
+	// inputs of format Format instead of validating any input.
+	CompileCheck bool
+
+	// TagStyle is the style marshaling tags must follow,
+	// one of tagStyles, not checked if empty.
+	TagStyle string
+
+	// RequireTagOnAll requires the marshaling tag on every field of every
+	// struct reachable from the type, including embedded fields,
+	// unless tagged "-".
+	RequireTagOnAll bool
+
+	// WarnExtraFiles reports a warning for every file next to the config
+	// file with a supported input format that isn't validated by any target.
+	WarnExtraFiles bool
+}
+
+// TypeMapping selects the type that input files with names matching
+// Pattern are validated against.
+type TypeMapping struct {
+	Pattern  string
+	TypeName string
 }
 
-func parseCLIParameters(args []string) (Params, error) {
-	var params Params
+// parseCLIParameters parses the command line arguments args.
+// Params.Silent is set on errors too, such that they aren't printed.
+func parseCLIParameters(args []string) (params Params, err error) {
 	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	// Errors and usage of the flag package are printed after parsing
+	// unless -silent is set.
+	var flagOutput bytes.Buffer
+	f.SetOutput(&flagOutput)
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "Usage of %s:\n", args[0])
+		f.PrintDefaults()
+		fmt.Fprint(f.Output(), exitCodesUsage)
+	}
 	f.StringVar(&params.PackageDir, "p", ".", "package directory path")
-	f.StringVar(&params.TypeName, "t", "", "type name")
-	f.StringVar(&params.InputFile, "f", "", "path to input file")
+	var typeNames []string
+	f.Func("t", "type name, can be repeated with -any", func(s string) error {
+		typeNames = append(typeNames, s)
+		return nil
+	})
+	anyType := f.Bool(
+		"any", false, "the input must match any of the types selected by -t",
+	)
+	var inputFiles []string
+	f.Func(
+		"f", "path to input file, glob pattern or HTTP(S) URL, can be repeated",
+		func(s string) error {
+			// Not split on commas, which paths and URLs may contain.
+			files, err := expandInputFile(s)
+			if err != nil {
+				return err
+			}
+			inputFiles = append(inputFiles, files...)
+			return nil
+		},
+	)
 	f.BoolVar(&params.InputEnv, "env", false, "use environment variables as input")
+	f.BoolVar(
+		&params.Stdin,
+		"stdin", false, "reads the input in the format selected by -format "+
+			"from the standard input",
+	)
+	f.BoolVar(
+		&params.Watch,
+		"watch", false, "validates again whenever an input file or the Go "+
+			"package changes until interrupted",
+	)
 	f.BoolVar(
 		&params.NoTagCheck,
 		"no-tag-check", false, "disables check of marshaling tags if set",
 	)
-	if err := f.Parse(args[1:]); err != nil {
+	f.BoolVar(
+		&params.FieldsRequiredByDefault,
+		"fields-required-by-default", false,
+		"requires all fields to be present in the input unless "+
+			"tagged valfile:\"optional\" or omitempty",
+	)
+	f.Func(
+		"kind",
+		"maps kind to type name (kind=Type) for multi-document YAML files, "+
+			"each document is validated against the type of its \"kind\" field",
+		func(s string) error {
+			kind, typeName, ok := strings.Cut(s, "=")
+			if !ok || kind == "" || typeName == "" {
+				return fmt.Errorf("invalid kind mapping %q, expected kind=Type", s)
+			}
+			if params.KindTypes == nil {
+				params.KindTypes = map[string]string{}
+			}
+			params.KindTypes[kind] = typeName
+			return nil
+		},
+	)
+	f.Func(
+		"field",
+		"maps key to type name (key=Type), the value of each key of the input "+
+			"is validated against its type, can be repeated",
+		func(s string) error {
+			key, typeName, ok := strings.Cut(s, "=")
+			if !ok || key == "" || typeName == "" {
+				return fmt.Errorf("invalid field mapping %q, expected key=Type", s)
+			}
+			if params.FieldTypes == nil {
+				params.FieldTypes = map[string]string{}
+			}
+			params.FieldTypes[key] = typeName
+			return nil
+		},
+	)
+	f.Func(
+		"ext-str",
+		"sets the Jsonnet external variable key to the string value (key=value), "+
+			"can be repeated",
+		func(s string) error {
+			key, value, ok := strings.Cut(s, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid external variable %q, expected key=value", s)
+			}
+			if params.JsonnetExtStr == nil {
+				params.JsonnetExtStr = map[string]string{}
+			}
+			params.JsonnetExtStr[key] = value
+			return nil
+		},
+	)
+	f.Func(
+		"ext-code",
+		"sets the Jsonnet external variable key to the Jsonnet expression "+
+			"(key=expr), can be repeated",
+		func(s string) error {
+			key, code, ok := strings.Cut(s, "=")
+			if !ok || key == "" || code == "" {
+				return fmt.Errorf("invalid external variable %q, expected key=expr", s)
+			}
+			if params.JsonnetExtCode == nil {
+				params.JsonnetExtCode = map[string]string{}
+			}
+			params.JsonnetExtCode[key] = code
+			return nil
+		},
+	)
+	f.Func(
+		"jpath", "adds a library search path for Jsonnet imports, can be repeated",
+		func(s string) error {
+			params.JsonnetPaths = append(params.JsonnetPaths, s)
+			return nil
+		},
+	)
+	f.Func(
+		"tag-fallback",
+		"comma-separated list of tags used, in order of priority, "+
+			"for fields lacking the marshaling tag of the input format",
+		func(s string) error {
+			params.TagFallback = strings.Split(s, ",")
+			return nil
+		},
+	)
+	f.StringVar(
+		&params.Output,
+		"output", OutputText, "output format ("+strings.Join(outputFormats, ", ")+")",
+	)
+	f.StringVar(
+		&params.OutputTemplate,
+		"output-template", "", "path of a text/template file to render the report with",
+	)
+	f.BoolVar(
+		&params.ReportUnused,
+		"report-unused", false, "warns about fields not set by any input "+
+			"validated against their type",
+	)
+	f.BoolVar(
+		&params.WarnZero,
+		"warn-zero", false, "warns about fields that are the zero value after decoding",
+	)
+	f.BoolVar(
+		&params.Strict,
+		"strict", false, "reports warnings as errors",
+	)
+	f.BoolVar(
+		&params.SOPS,
+		"sops", false, "validates the structure of SOPS encrypted YAML and JSON "+
+			"files ignoring their metadata and encrypted values",
+	)
+	f.BoolVar(
+		&params.StrictStrings,
+		"strict-strings", false, "warns about unquoted YAML values of string "+
+			"fields that YAML resolves to another type, such as no or 1.10",
+	)
+	f.BoolVar(
+		&params.MultiDoc,
+		"multi-doc", false, "validates every document of YAML inputs "+
+			"against the type instead of only the first",
+	)
+	f.BoolVar(
+		&params.PrintEffective,
+		"print-effective", false, "prints the decoded value of valid inputs",
+	)
+	f.StringVar(
+		&params.EffectiveFormat,
+		"effective-format", "", "format of -print-effective, "+
+			"either json or the input format (default)",
+	)
+	f.StringVar(
+		&params.ReportDefaults,
+		"report-defaults", "", "reports optional fields not set by the input "+
+			"in the given format ("+strings.Join(reportDefaultsFormats, ", ")+")",
+	)
+	f.StringVar(
+		&params.ReportDefaultsFile,
+		"report-defaults-file", "", "path of the file -report-defaults writes "+
+			"the report to (default: stdout)",
+	)
+	f.StringVar(
+		&params.Archive,
+		"archive", "", "path to a tar, tar.gz or zip archive containing the input file",
+	)
+	f.StringVar(
+		&params.ArchiveEntry,
+		"entry", "", "path of the input file inside the archive",
+	)
+	f.BoolVar(
+		&params.Silent,
+		"silent", false, "prints nothing, the exit code is the only output",
+	)
+	concise := f.Bool(
+		"concise", false,
+		"prints one line per error (file:line: [CODE] message), "+
+			"same as -output "+OutputConcise,
+	)
+	f.StringVar(
+		&params.InputDir,
+		"input-dir", "", "path to a directory of input files validated "+
+			"against the types selected by -map",
+	)
+	f.Func(
+		"map",
+		"maps a file name pattern to a type name (pattern=Type) for -input-dir, "+
+			"the first matching pattern selects the type of a file",
+		func(s string) error {
+			pattern, typeName, ok := strings.Cut(s, "=")
+			if !ok || pattern == "" || typeName == "" {
+				return fmt.Errorf("invalid mapping %q, expected pattern=Type", s)
+			}
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			params.TypeMappings = append(params.TypeMappings, TypeMapping{
+				Pattern: pattern, TypeName: typeName,
+			})
+			return nil
+		},
+	)
+	f.StringVar(
+		&params.BaseDir,
+		"base-dir", "", "directory relative paths of fields tagged valfile:\"file\" "+
+			"or valfile:\"dir\" are resolved against "+
+			"(default: directory of the input file)",
+	)
+	f.BoolVar(
+		&params.ExplainType,
+		"explain-type", false, "prints the selected type and all types it depends on "+
+			"as seen by the generated program, the input is optional",
+	)
+	f.BoolVar(
+		&params.EmitSchema,
+		"emit-schema", false, "prints a JSON Schema (draft 2020-12) of the type "+
+			"instead of validating, property names are determined by the "+
+			"marshaling tag of the input format (default: json)",
+	)
+	f.StringVar(
+		&params.ConfigFile,
+		"config", "", "path to config file declaring targets (e.g. "+
+			DefaultConfigFileName+")",
+	)
+	f.Int64Var(
+		&params.MaxInputSize,
+		"max-input-size", DefaultMaxInputSize,
+		"maximum size of an input file in bytes, 0 means unlimited",
+	)
+	f.DurationVar(
+		&params.Timeout,
+		"timeout", DefaultTimeout, "timeout of requests fetching input URLs",
+	)
+	f.Func(
+		"expect-sha256", "hex-encoded SHA-256 checksum the input file must match",
+		func(s string) error {
+			b, err := hex.DecodeString(s)
+			if err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("invalid SHA-256 checksum %q", s)
+			}
+			params.ExpectSHA256 = hex.EncodeToString(b)
+			return nil
+		},
+	)
+	f.StringVar(
+		&params.MergePatch,
+		"merge-patch", "", "path to a JSON Merge Patch (RFC 7386) applied to -base, "+
+			"the result is validated",
+	)
+	f.StringVar(
+		&params.MergeBase,
+		"base", "", "path to the JSON file -merge-patch is applied to",
+	)
+	f.Func(
+		"set",
+		"sets a value (key.path=value) of an input constructed on the fly "+
+			"in the format selected by -format, can be repeated",
+		func(s string) error {
+			key, value, ok := strings.Cut(s, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid override %q, expected key=value", s)
+			}
+			params.Overrides = append(params.Overrides, Override{
+				Path: strings.Split(key, "."), Value: value,
+			})
+			return nil
+		},
+	)
+	f.BoolVar(
+		&params.FailOnEmpty,
+		"fail-on-empty", false, "reports empty inputs and inputs "+
+			"decoding to a zero value as an error",
+	)
+	f.BoolVar(
+		&params.EnvExact,
+		"env-exact", false, "requires every field to be set and every "+
+			"environment variable to be consumed by a field, "+
+			"requires -env-prefix with -env",
+	)
+	f.BoolVar(
+		&params.EnvStrict,
+		"env-strict", false, "reports environment variables that aren't "+
+			"consumed by any field, requires -env-prefix with -env",
+	)
+	f.BoolVar(
+		&params.EnvCaseInsensitive,
+		"env-case-insensitive", false, "matches the names of environment "+
+			"variables against env tags case-insensitively",
+	)
+	f.StringVar(
+		&params.EnvPrefix,
+		"env-prefix", "", "uses only the environment variables starting with "+
+			"the prefix as input and strips it from their names",
+	)
+	f.BoolVar(
+		&params.EnvrcStrict,
+		"envrc-strict", false, "rejects shell statements in .envrc files "+
+			"other than variable assignments instead of ignoring them",
+	)
+	f.BoolVar(
+		&params.LintTags,
+		"lint-tags", false, "checks the tags of all exported struct types "+
+			"of the package for the input format selected by -format",
+	)
+	f.BoolVar(
+		&params.Enums,
+		"enums", false, "reports values of integer types with declared "+
+			"constants that aren't one of them",
+	)
+	f.StringVar(
+		&params.Workspace,
+		"workspace", "", "persistent directory the generated programs are set up "+
+			"in and reused across runs, temporary directories are used if empty",
+	)
+	f.BoolVar(
+		&params.ValidateTags,
+		"validate", false, "checks the syntax of validate tags along with "+
+			"the marshaling tags, values are always validated against them",
+	)
+	f.BoolVar(
+		&params.RequireValidate,
+		"require-validate", false, "reports fields lacking a validate tag, "+
+			"validate:\"-\" opts out, implies -validate",
+	)
+	f.BoolVar(
+		&params.KeepTemp,
+		"keep-temp", false, "keeps the temporary directories of the generated "+
+			"programs for debugging and prints their paths to stderr, implies -no-cache",
+	)
+	f.StringVar(
+		&params.GoCommand,
+		"go", os.Getenv("VALFILE_GO"), "path of the go command compiling the "+
+			"generated program, overrides the VALFILE_GO environment variable "+
+			"(default: go from PATH)",
+	)
+	noCache := f.Bool(
+		"no-cache", false, "compiles the generated programs instead of running "+
+			"the compiled programs cached in the user cache directory",
+	)
+	f.BoolVar(
+		&params.CompileCheck,
+		"compile-check", false, "renders and compiles the generated program "+
+			"for the input format selected by -format without validating any input",
+	)
+	f.StringVar(
+		&params.TagStyle,
+		"tag-style", "", "requires marshaling tags to be the Go field name "+
+			"in the given case ("+strings.Join(tagStyles, ", ")+")",
+	)
+	f.BoolVar(
+		&params.RequireTagOnAll,
+		"require-tag-on-all", false, "requires the marshaling tag on all fields "+
+			"including embedded fields",
+	)
+	f.Func(
+		"format",
+		"input format ("+strings.Join(formatNames, ", ")+")",
+		func(s string) (err error) {
+			params.Format, err = parseFormat(s)
+			return err
+		},
+	)
+	f.BoolVar(
+		&params.WarnExtraFiles,
+		"warn-extra-files", false, "warns about files in the directory of the "+
+			"config file and its subdirectories that aren't validated by any target",
+	)
+	err = f.Parse(args[1:])
+	silent := params.Silent
+	defer func() { params.Silent = silent }()
+	if !silent {
+		_, _ = flagOutput.WriteTo(os.Stderr)
+	}
+	if err != nil {
 		return Params{}, err
 	}
 
-	switch {
-	case params.PackageDir == "":
-		return Params{}, errors.New("missing package directory")
-	case params.TypeName == "":
-		return Params{}, errors.New("missing type name")
-	case !params.InputEnv && params.InputFile == "":
-		return Params{}, errors.New("missing input file")
-	case params.InputEnv && params.InputFile != "":
-		return Params{}, errors.New("conflicting parameters, " +
-			"-env and -f are mutually exlusive. " +
-			"Please use either the -env option or the -f option, but not both.")
-	}
+	if !*noCache && !params.KeepTemp {
+		// Caching is best-effort, programs are compiled on every run
+		// if there's no user cache directory.
+		if dir, err := userCacheDir(); err == nil {
+			params.CacheDir = filepath.Join(dir, "valfile")
+		}
+	}
+
+	switch {
+	case len(typeNames) > 1 && !*anyType:
+		return Params{}, errors.New("multiple types require -any")
+	case *anyType && len(typeNames) < 2:
+		return Params{}, errors.New("-any requires multiple types")
+	case *anyType:
+		params.AnyTypes = typeNames
+	}
+	if len(typeNames) > 0 {
+		params.TypeName = typeNames[0]
+	}
+	if len(inputFiles) > 0 {
+		params.InputFile = inputFiles[0]
+	}
+	if len(inputFiles) > 1 {
+		params.InputFiles = inputFiles
+	}
+
+	if *concise {
+		if params.Output != OutputText && params.Output != OutputConcise {
+			return Params{}, errors.New("conflicting parameters, " +
+				"-concise and -output are mutually exclusive")
+		}
+		params.Output = OutputConcise
+	}
+	if !slices.Contains(outputFormats, params.Output) {
+		return Params{}, fmt.Errorf("unsupported output format: %q", params.Output)
+	}
+	if params.OutputTemplate != "" && params.Output != OutputText {
+		return Params{}, errors.New("conflicting parameters, " +
+			"-output-template and -output are mutually exclusive")
+	}
+	if params.EffectiveFormat != "" && !params.PrintEffective {
+		return Params{}, errors.New("-effective-format requires -print-effective")
+	}
+	if params.ReportDefaults != "" &&
+		!slices.Contains(reportDefaultsFormats, params.ReportDefaults) {
+		return Params{}, fmt.Errorf(
+			"unsupported defaults report format: %q", params.ReportDefaults,
+		)
+	}
+	if params.ReportDefaultsFile != "" && params.ReportDefaults == "" {
+		return Params{}, errors.New("-report-defaults-file requires -report-defaults")
+	}
+	if params.ReportDefaults != "" && params.ReportDefaultsFile == "" &&
+		params.PrintEffective {
+		return Params{}, errors.New("-report-defaults requires " +
+			"-report-defaults-file with -print-effective, both write to stdout")
+	}
+
+	if params.TagStyle != "" && !slices.Contains(tagStyles, params.TagStyle) {
+		return Params{}, fmt.Errorf("unsupported tag style: %q", params.TagStyle)
+	}
+	if params.RequireTagOnAll && params.NoTagCheck {
+		return Params{}, errors.New("conflicting parameters, " +
+			"-require-tag-on-all and -no-tag-check are mutually exclusive")
+	}
+
+	if params.LintTags {
+		switch {
+		case params.Format == 0:
+			return Params{}, errors.New("missing input format, use -format")
+		case params.TypeName != "" || params.InputFile != "" || params.InputEnv ||
+			params.ConfigFile != "" || params.InputDir != "" || params.Archive != "" ||
+			params.KindTypes != nil:
+			return Params{}, errors.New("conflicting parameters, -lint-tags " +
+				"is mutually exclusive with -t, -kind, -f, -archive, -env, " +
+				"-input-dir and -config")
+		}
+		return params, nil
+	}
+
+	if params.CompileCheck {
+		switch {
+		case params.Format == 0:
+			return Params{}, errors.New("missing input format, use -format")
+		case params.InputFile != "" || params.InputEnv || params.ConfigFile != "" ||
+			params.InputDir != "" || params.Archive != "" ||
+			params.Overrides != nil || params.MergePatch != "":
+			return Params{}, errors.New("conflicting parameters, -compile-check " +
+				"is mutually exclusive with -f, -archive, -env, -set, -merge-patch, " +
+				"-input-dir and -config")
+		}
+	}
+
+	if params.ConfigFile != "" {
+		if params.TypeName != "" || params.InputFile != "" || params.InputEnv {
+			return Params{}, errors.New("conflicting parameters, " +
+				"-config is mutually exclusive with -t, -f and -env")
+		}
+		return params, nil
+	} else if params.WarnExtraFiles {
+		return Params{}, errors.New("-warn-extra-files requires -config")
+	}
+
+	if params.InputDir != "" {
+		switch {
+		case params.TypeMappings == nil:
+			return Params{}, errors.New("missing type mappings, use -map pattern=Type")
+		case params.TypeName != "" || params.InputFile != "" || params.InputEnv ||
+			params.KindTypes != nil || params.Archive != "":
+			return Params{}, errors.New("conflicting parameters, -input-dir " +
+				"is mutually exclusive with -t, -kind, -f, -archive and -env")
+		}
+		return params, nil
+	} else if params.TypeMappings != nil {
+		return Params{}, errors.New("-map requires -input-dir")
+	}
+
+	if params.Archive != "" {
+		switch {
+		case params.ArchiveEntry == "":
+			return Params{}, errors.New("missing archive entry")
+		case params.InputFile != "" || params.InputEnv:
+			return Params{}, errors.New("conflicting parameters, " +
+				"-archive is mutually exclusive with -f and -env")
+		}
+		params.InputFile = params.Archive
+	} else if params.ArchiveEntry != "" {
+		return Params{}, errors.New("-entry requires -archive")
+	}
+
+	if params.Overrides != nil {
+		switch {
+		case params.Format == 0:
+			return Params{}, errors.New("missing input format, use -format")
+		case params.InputFile != "" || params.InputEnv:
+			return Params{}, errors.New("conflicting parameters, " +
+				"-set is mutually exclusive with -f, -archive and -env")
+		}
+	}
+
+	if params.Stdin {
+		switch {
+		case params.Format == 0:
+			return Params{}, errors.New("missing input format, use -format")
+		case params.InputFile != "" || params.InputEnv ||
+			params.Overrides != nil || params.MergePatch != "":
+			return Params{}, errors.New("conflicting parameters, " +
+				"-stdin is mutually exclusive with -f, -archive, -env, -set " +
+				"and -merge-patch")
+		}
+	}
+
+	if params.MergePatch != "" || params.MergeBase != "" {
+		switch {
+		case params.MergeBase == "":
+			return Params{}, errors.New("missing merge patch base, use -base")
+		case params.MergePatch == "":
+			return Params{}, errors.New("missing merge patch, use -merge-patch")
+		case params.InputFile != "" || params.InputEnv || params.Overrides != nil:
+			return Params{}, errors.New("conflicting parameters, -merge-patch " +
+				"is mutually exclusive with -f, -archive, -env and -set")
+		}
+	}
+
+	if params.Watch && (params.Stdin || params.ExplainType || params.EmitSchema) {
+		return Params{}, errors.New("conflicting parameters, " +
+			"-watch is mutually exclusive with -stdin, -explain-type and -emit-schema")
+	}
+	if params.EmitSchema && params.ExplainType {
+		return Params{}, errors.New("conflicting parameters, " +
+			"-emit-schema and -explain-type are mutually exclusive")
+	}
+
+	if params.ExpectSHA256 != "" && params.InputFile == "" {
+		return Params{}, errors.New("-expect-sha256 requires -f or -archive")
+	}
+	if params.ExpectSHA256 != "" && params.InputFiles != nil {
+		return Params{}, errors.New("-expect-sha256 requires a single input file")
+	}
+
+	switch {
+	case params.PackageDir == "":
+		return Params{}, errors.New("missing package directory")
+	case params.TypeName == "" && params.KindTypes == nil && params.FieldTypes == nil:
+		return Params{}, errors.New("missing type name")
+	case params.TypeName != "" && params.KindTypes != nil:
+		return Params{}, errors.New("conflicting parameters, " +
+			"-t and -kind are mutually exclusive")
+	case params.MultiDoc && params.KindTypes != nil:
+		return Params{}, errors.New("conflicting parameters, " +
+			"-multi-doc and -kind are mutually exclusive")
+	case params.FieldTypes != nil && (params.TypeName != "" || params.KindTypes != nil):
+		return Params{}, errors.New("conflicting parameters, " +
+			"-field is mutually exclusive with -t and -kind")
+	case !params.InputEnv && params.InputFile == "" && params.Overrides == nil &&
+		params.MergePatch == "" && !params.Stdin &&
+		!params.ExplainType && !params.EmitSchema && !params.CompileCheck:
+		return Params{}, errors.New("missing input file")
+	case params.EmitSchema && (params.KindTypes != nil || params.AnyTypes != nil):
+		return Params{}, errors.New("-emit-schema requires a single type, " +
+			"-kind and -any aren't supported")
+	case params.EnvPrefix != "" && !params.InputEnv:
+		return Params{}, errors.New("-env-prefix requires -env")
+	case params.EnvStrict && params.InputEnv && params.EnvPrefix == "":
+		// All variables of the environment would be reported.
+		return Params{}, errors.New("-env-strict requires -env-prefix with -env")
+	case params.EnvExact && params.InputEnv && params.EnvPrefix == "":
+		return Params{}, errors.New("-env-exact requires -env-prefix with -env")
+	case params.InputEnv && params.InputFile != "":
+		return Params{}, errors.New("conflicting parameters, " +
+			"-env and -f are mutually exlusive. " +
+			"Please use either the -env option or the -f option, but not both.")
+	}
+
+	return params, nil
+}
+
+// expandInputFile returns the files matching the glob pattern p in
+// lexical order, or p itself if it's not a pattern.
+func expandInputFile(p string) ([]string, error) {
+	if isURL(p) || !strings.ContainsAny(p, "*?[") {
+		return []string{p}, nil
+	}
+	files, err := filepath.Glob(p)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+	case len(files) < 1:
+		return nil, fmt.Errorf("no input files match %q", p)
+	}
+	return files, nil
+}
+
+// TemplateData is the data the program templates are executed with.
+type TemplateData struct {
+	TypeDefinitions []string
+	RootTypeName    string
+
+	// Imports are additional import specs required by TypeDefinitions.
+	Imports []string
+
+	// Enums maps the names of integer types to the decimal values
+	// of their declared constants.
+	Enums map[string][]string
+
+	// Input is a string for file formats
+	// and a map[string]string for environment variables.
+	Input any
+
+	InputFileName       string
+	StdoutErrPrefix     string
+	StdoutWarnPrefix    string
+	StdoutDefaultPrefix string
+
+	StdoutEffectivePrefix string
+
+	// KindTypes maps values of the "kind" field of YAML documents
+	// to the names of the types the documents are validated against.
+	KindTypes map[string]string
+
+	// Tag is the marshaling tag of the input format.
+	Tag string
+
+	// FieldsRequiredByDefault makes all fields required unless
+	// they're marked optional.
+	FieldsRequiredByDefault bool
+
+	// BaseDir is the absolute path of the directory relative paths
+	// of fields tagged valfile:"file" or valfile:"dir" are resolved against.
+	BaseDir string
+
+	// FailOnEmpty reports inputs decoding to a zero value.
+	FailOnEmpty bool
+
+	// EnvExact reports unset fields and environment variables
+	// that aren't consumed by any field.
+	EnvExact bool
+
+	// EnvStrict reports environment variables
+	// that aren't consumed by any field.
+	EnvStrict bool
+
+	// EnvPrefix is the prefix stripped from the names of environment
+	// variables, which is added back when reporting them.
+	EnvPrefix string
+
+	// EnvCaseInsensitive matches the names of environment variables
+	// against the env tags of fields case-insensitively.
+	EnvCaseInsensitive bool
+
+	// ReportDefaults reports optional fields that aren't set.
+	ReportDefaults bool
+
+	// WarnZero reports fields that are the zero value after decoding.
+	WarnZero bool
+
+	// SOPS ignores the metadata and the encrypted values of SOPS files.
+	SOPS bool
 
-	return params, nil
+	// StrictStrings reports plain YAML scalars of string fields
+	// that aren't strings in YAML 1.1 or 1.2.
+	StrictStrings bool
+
+	// MultiDoc validates every document of the input against the root type.
+	MultiDoc bool
+
+	// EffectiveFormat is the format the decoded value is printed in,
+	// nothing is printed if empty.
+	EffectiveFormat string
 }
 
-func mustRenderSrc(
-	typeDefinitions []string,
-	rootTypeName, input, fileName string,
-	tmpl *template.Template,
-) []byte {
+func mustRenderSrc(tmpl *template.Template, data TemplateData) []byte {
 	b := new(bytes.Buffer)
-	if err := tmpl.Execute(b, struct {
-		TypeDefinitions []string
-		RootTypeName    string
-		Input           string
-		InputFileName   string
-		StdoutErrPrefix string
-	}{
-		TypeDefinitions: typeDefinitions,
-		RootTypeName:    rootTypeName,
-		Input:           input,
-		InputFileName:   fileName,
-		StdoutErrPrefix: StdoutErrPrefix,
-	}); err != nil {
+	if err := tmpl.Execute(b, data); err != nil {
 		panic(fmt.Errorf("executing template: %w", err))
 	}
-	return b.Bytes()
+	return removeDuplicateImports(b.Bytes())
 }
 
-func mustRenderSrcEnv(
-	typeDefinitions []string,
-	rootTypeName string,
-	input map[string]string,
-) []byte {
-	b := new(bytes.Buffer)
-	if err := tmplENV.Execute(b, struct {
-		TypeDefinitions []string
-		RootTypeName    string
-		Input           map[string]string
-		StdoutErrPrefix string
-	}{
-		TypeDefinitions: typeDefinitions,
-		RootTypeName:    rootTypeName,
-		Input:           input,
-		StdoutErrPrefix: StdoutErrPrefix,
-	}); err != nil {
-		panic(fmt.Errorf("executing template: %w", err))
+// removeDuplicateImports removes import specs from src that are identical
+// to a preceding import spec, which happens when the types require
+// packages the template already imports.
+func removeDuplicateImports(src []byte) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		panic(fmt.Errorf("parsing rendered source: %w", err))
+	}
+	seen := map[string]bool{}
+	var dups []*ast.ImportSpec
+	for _, imp := range f.Imports {
+		key := imp.Path.Value
+		if imp.Name != nil {
+			key = imp.Name.Name + " " + key
+		}
+		if seen[key] {
+			dups = append(dups, imp)
+		}
+		seen[key] = true
+	}
+	for i := len(dups) - 1; i >= 0; i-- {
+		start := fset.Position(dups[i].Pos()).Offset
+		end := fset.Position(dups[i].End()).Offset
+		src = append(src[:start], src[end:]...)
+	}
+	return src
+}
+
+// resolvedTypes are the types required by the generated program.
+type resolvedTypes struct {
+	Specs       map[string]*ast.TypeSpec
+	Definitions []string
+
+	// Names are the names of the types in the order of Definitions.
+	Names []string
+
+	// Imports are the import specs required by Definitions.
+	Imports map[string]struct{}
+
+	// Root is the name of the type inputs are decoded into.
+	Root string
+
+	// Roots maps the root types as specified, which may be instantiations
+	// of generic types, to the names of the types in Definitions.
+	Roots map[string]string
+
+	// Enums maps the names of integer types to the values of their declared
+	// constants, which is only set if Params.Enums is set.
+	Enums map[string][]string
+}
+
+// resolveTypes resolves the root types and all types they depend on.
+// Types of other packages of the same module or its vendor directory
+// are inlined, see typeResolver.
+func resolveTypes(
+	fset *token.FileSet,
+	pkg *ast.Package,
+	rootTypeNames ...string,
+) (r resolvedTypes, errs []error) {
+	r.Specs = map[string]*ast.TypeSpec{}
+	r.Imports = map[string]struct{}{}
+	res := newTypeResolver(fset, pkg, &r)
+
+	r.Roots = map[string]string{}
+	for _, rootTypeName := range rootTypeNames {
+		if _, ok := r.Roots[rootTypeName]; ok {
+			continue
+		}
+		local, err := res.root(rootTypeName)
+		if err != nil {
+			return resolvedTypes{}, []error{err}
+		}
+		r.Roots[rootTypeName] = local
+	}
+	if res.errs != nil {
+		// Reported after resolving all root types
+		// to report all undefined types at once.
+		return resolvedTypes{}, res.errs
+	}
+	for _, name := range r.Names {
+		def, err := renderGoType(r.Specs[name], fset)
+		if err != nil {
+			return resolvedTypes{}, []error{fmt.Errorf("rendering go type: %w", err)}
+		}
+		r.Definitions = append(r.Definitions, def)
 	}
-	return b.Bytes()
+	return r, nil
 }
 
 func parsePackage(fset *token.FileSet, packageDirPath string) (*ast.Package, error) {
@@ -417,101 +1799,592 @@ func parsePackage(fset *token.FileSet, packageDirPath string) (*ast.Package, err
 	return nil, nil
 }
 
+// findType returns the declaration of the type typeName in pkg,
+// or nil if pkg doesn't declare it. Types are looked up in the package
+// scope, which is built from the file scopes on first use.
 func findType(
 	fset *token.FileSet,
 	pkg *ast.Package,
 	typeName string,
 ) *ast.TypeSpec {
-	for _, file := range pkg.Files {
-		for _, obj := range file.Scope.Objects {
-			if obj.Kind != ast.Typ {
-				continue
-			}
-			if obj.Name != typeName {
-				continue
+	if pkg.Scope == nil {
+		pkg.Scope = newPackageScope(pkg)
+	}
+	if obj := pkg.Scope.Lookup(typeName); obj != nil {
+		return obj.Decl.(*ast.TypeSpec)
+	}
+	return nil
+}
+
+// newPackageScope returns a scope of the types declared in the files of pkg.
+// If multiple files declare a type of the same name, the first file
+// in lexical order wins.
+func newPackageScope(pkg *ast.Package) *ast.Scope {
+	s := ast.NewScope(nil)
+	for _, k := range sortedKeys(pkg.Files) {
+		for _, obj := range pkg.Files[k].Scope.Objects {
+			if obj.Kind == ast.Typ && s.Lookup(obj.Name) == nil {
+				s.Insert(obj)
 			}
-			return obj.Decl.(*ast.TypeSpec)
 		}
 	}
-	return nil
+	return s
 }
 
-func checkMarshalingTags(t *ast.TypeSpec, expectTag string) (errs []error) {
-	s, ok := t.Type.(*ast.StructType)
+// rootFieldType returns the name of the type of the field of type typeName
+// tagged valfile:"root", which the input is decoded into instead,
+// or typeName if there's no such field.
+func rootFieldType(fset *token.FileSet, pkg *ast.Package, typeName string) (string, error) {
+	spec := findType(fset, pkg, typeName)
+	if spec == nil {
+		return typeName, nil
+	}
+	s, ok := spec.Type.(*ast.StructType)
 	if !ok {
-		return nil
+		return typeName, nil
 	}
-
+	root := typeName
 	for _, f := range s.Fields.List {
-		var fieldName string
+		if f.Tag == nil || !hasValfileOption(f.Tag.Value, "root") {
+			continue
+		}
+		fieldName := embeddedFieldName(f.Type)
 		if len(f.Names) > 0 {
 			fieldName = f.Names[0].Name
-		} else if id, ok := f.Type.(*ast.Ident); ok {
-			fieldName = id.Name
 		}
-		addErrf := func(msg string, v ...any) {
-			errs = append(errs, fmt.Errorf(
-				"%s.%s: %s", t.Name.Name, fieldName, fmt.Sprintf(msg, v...),
-			))
+		if root != typeName {
+			return "", fmt.Errorf(
+				"%s: multiple fields tagged valfile:\"root\"", typeName,
+			)
 		}
-		if f.Tag == nil || f.Tag.Value == "" {
-			addErrf("missing tag %q", expectTag)
-			continue
+		t := f.Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
 		}
+		ident, ok := t.(*ast.Ident)
+		if !ok || findType(fset, pkg, ident.Name) == nil {
+			return "", fmt.Errorf("%s.%s: field tagged valfile:\"root\" must be "+
+				"of a type declared in the package", typeName, fieldName)
+		}
+		root = ident.Name
+	}
+	return root, nil
+}
 
-		tagContent, err := strconv.Unquote(f.Tag.Value)
-		if err != nil {
-			addErrf("unquoting tag: %v", err)
+// hasValfileOption returns true if the quoted struct tag
+// has a valfile tag with the given option.
+func hasValfileOption(quotedTag, option string) bool {
+	tag, err := strconv.Unquote(quotedTag)
+	if err != nil {
+		return false
+	}
+	v, ok := reflect.StructTag(tag).Lookup("valfile")
+	return ok && slices.Contains(strings.Split(v, ","), option)
+}
+
+// checkRewriteOptions reports the fields of t, including those of nested
+// anonymous structs, tagged valfile:"bytesize" or with aliases, which
+// programs for inputs of type inputType don't support since they have no
+// generic representation of the input to rewrite.
+func checkRewriteOptions(
+	fset *token.FileSet, t *ast.TypeSpec, inputType InputType,
+) (errs []error) {
+	switch inputType {
+	case InputTypeHCL, InputTypeXML, InputTypeINI:
+	default:
+		return nil
+	}
+	s, ok := t.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	var checkFields func(path string, s *ast.StructType)
+	checkFields = func(path string, s *ast.StructType) {
+		for _, f := range s.Fields.List {
+			fieldName := embeddedFieldName(f.Type)
+			if len(f.Names) > 0 {
+				fieldName = f.Names[0].Name
+			}
+			fieldPath := path + "." + fieldName
+			if n := anonymousStruct(f.Type); n != nil {
+				checkFields(fieldPath, n)
+			}
+			if f.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				continue
+			}
+			v, _ := reflect.StructTag(tag).Lookup("valfile")
+			for _, o := range strings.Split(v, ",") {
+				name, _, _ := strings.Cut(o, "=")
+				if name != "bytesize" && name != "aliases" {
+					continue
+				}
+				pos := fset.Position(f.Pos())
+				errs = append(errs, &Diagnostic{
+					File:   pos.Filename,
+					Line:   pos.Line,
+					Column: pos.Column,
+					Code:   CodeUsage,
+					Message: fmt.Sprintf("%s: valfile option %q isn't supported "+
+						"for %s input", fieldPath, name, inputType.MarshalingTag()),
+					Type:  t.Name.Name,
+					Field: strings.TrimPrefix(fieldPath, t.Name.Name+"."),
+				})
+			}
 		}
+	}
+	checkFields(t.Name.Name, s)
+	return errs
+}
 
-		tags, err := structtag.Parse(tagContent)
-		if err != nil {
-			addErrf("parsing struct tags: %v", err)
+// findDotImport returns the import path of the dot-imported standard library
+// package declaring the exported type typeName, or an empty string if
+// no dot-imported standard library package declares it.
+// Returns an error if typeName can only be declared by a dot-imported
+// package outside the standard library, which is not supported.
+func findDotImport(
+	stdImporter types.Importer,
+	pkg *ast.Package,
+	typeName string,
+) (string, error) {
+	if !ast.IsExported(typeName) {
+		return "", nil
+	}
+	var nonStd []string
+	for _, path := range dotImports(pkg) {
+		if !isStdPackage(path) {
+			nonStd = append(nonStd, path)
 			continue
 		}
-		tag, err := tags.Get(expectTag)
+		p, err := stdImporter.Import(path)
 		if err != nil {
-			if err.Error() == "tag does not exist" {
-				addErrf("missing tag %q", expectTag)
+			return "", fmt.Errorf("importing dot-imported package %q: %w", path, err)
+		}
+		if _, ok := p.Scope().Lookup(typeName).(*types.TypeName); ok {
+			return path, nil
+		}
+	}
+	if nonStd != nil {
+		return "", fmt.Errorf(
+			"undefined type: %s: identifier comes from dot-imported package %s, "+
+				"dot-imports of packages outside the standard library "+
+				"are not supported",
+			typeName, strings.Join(quoteAll(nonStd), " or "),
+		)
+	}
+	return "", nil
+}
+
+// findSelectorImport returns the import spec of the standard library package
+// declaring the qualified type s, such as big.Int, which is imported
+// by one of the files of pkg.
+func findSelectorImport(
+	stdImporter types.Importer,
+	pkg *ast.Package,
+	s *ast.SelectorExpr,
+) (string, error) {
+	x, ok := s.X.(*ast.Ident)
+	if !ok {
+		return "", fmt.Errorf("unsupported type expression: %T", s.X)
+	}
+	typeName := x.Name + "." + s.Sel.Name
+	for _, k := range sortedKeys(pkg.Files) {
+		for _, imp := range pkg.Files[k].Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
 				continue
 			}
-			addErrf("getting tag %q: %v", expectTag, err)
-			continue
+			name := ""
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if name == "." || name == "_" {
+				continue
+			}
+			if !isStdPackage(path) {
+				// Resolved by typeResolver.
+				continue
+			}
+			p, err := stdImporter.Import(path)
+			if err != nil {
+				return "", fmt.Errorf("importing package %q: %w", path, err)
+			}
+			if name == "" {
+				name = p.Name()
+			}
+			if name != x.Name {
+				continue
+			}
+			if _, ok := p.Scope().Lookup(s.Sel.Name).(*types.TypeName); !ok {
+				return "", fmt.Errorf("undefined type: %s", typeName)
+			}
+			if name != p.Name() {
+				return name + " " + strconv.Quote(path), nil
+			}
+			return strconv.Quote(path), nil
 		}
-		if tag.Name == "" {
-			addErrf("tag %q is empty", expectTag)
-			continue
+	}
+	return "", fmt.Errorf("undefined type: %s", typeName)
+}
+
+// dotImports returns the sorted import paths of all dot-imports in pkg.
+func dotImports(pkg *ast.Package) []string {
+	paths := map[string]struct{}{}
+	for _, file := range pkg.Files {
+		for _, imp := range file.Imports {
+			if imp.Name == nil || imp.Name.Name != "." {
+				continue
+			}
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			paths[path] = struct{}{}
 		}
 	}
-	return errs
+	return sortedKeys(paths)
+}
+
+// isStdPackage returns true if importPath refers to a standard library package.
+func isStdPackage(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+func quoteAll(s []string) []string {
+	q := make([]string, len(s))
+	for i := range s {
+		q[i] = strconv.Quote(s[i])
+	}
+	return q
 }
 
-func traverseTypeIdents(
+// checkMarshalingTags checks the expectTag tags of the fields of t
+// and of anonymous struct types nested in t, which are reported by their
+// dotted paths such as Config.Metrics.Port.
+// Unless style is empty, tag names must be the field names in that style.
+// If requireAll is true, embedded fields must be tagged as well.
+func checkMarshalingTags(
 	fset *token.FileSet,
-	pkg *ast.Package,
-	e ast.Expr,
-	fn func(*ast.Ident) (stop bool),
-) {
+	t *ast.TypeSpec,
+	expectTag string,
+	style string,
+	requireAll bool,
+) (errs []error) {
+	s, ok := t.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+
+	var checkFields func(path string, s *ast.StructType)
+	checkFields = func(path string, s *ast.StructType) {
+		// seen maps the tag names of the fields of s to their paths.
+		seen := map[string]string{}
+		for _, f := range s.Fields.List {
+			var fieldName string
+			embedded := len(f.Names) < 1
+			if !embedded {
+				fieldName = f.Names[0].Name
+			} else {
+				fieldName = embeddedFieldName(f.Type)
+			}
+			fieldPath := path + "." + fieldName
+			addErrf := func(msg string, v ...any) {
+				pos := fset.Position(f.Pos())
+				errs = append(errs, &Diagnostic{
+					File:    pos.Filename,
+					Line:    pos.Line,
+					Column:  pos.Column,
+					Code:    CodeTag,
+					Message: fmt.Sprintf("%s: %s", fieldPath, fmt.Sprintf(msg, v...)),
+					Type:    t.Name.Name,
+					Field:   strings.TrimPrefix(fieldPath, t.Name.Name+"."),
+				})
+			}
+			// promoted is true if the fields of an untagged
+			// embedded struct are promoted.
+			promoted := embedded && promotesEmbedded(expectTag) && !requireAll
+			if name := tagName(f, expectTag); name != "" {
+				if first, ok := seen[name]; ok {
+					name, _, _ = strings.Cut(name, ",")
+					addErrf("duplicate %s tag %q (also on %s)", expectTag, name, first)
+				} else {
+					seen[name] = fieldPath
+				}
+			}
+			if checkFieldTag(f, expectTag, style, fieldName, promoted, addErrf) {
+				if n := anonymousStruct(f.Type); n != nil {
+					checkFields(fieldPath, n)
+				}
+			}
+		}
+	}
+	checkFields(t.Name.Name, s)
+	return errs
+}
+
+// tagName returns the name in the expectTag tag of f, or an empty string
+// if it has none or is "-". Names of XML attributes are suffixed by ",attr"
+// since they don't collide with the names of elements, and names of HCL
+// block labels by ",label" since they don't collide with attributes.
+func tagName(f *ast.Field, expectTag string) string {
+	if f.Tag == nil {
+		return ""
+	}
+	tagContent, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	tags, err := structtag.Parse(tagContent)
+	if err != nil {
+		return ""
+	}
+	tag, err := tags.Get(expectTag)
+	if err != nil || tag.Name == "-" {
+		return ""
+	}
+	if expectTag == "xml" && tag.HasOption("attr") {
+		return tag.Name + ",attr"
+	}
+	if expectTag == "hcl" && tag.HasOption("label") {
+		return tag.Name + ",label"
+	}
+	return tag.Name
+}
+
+// checkFieldTag checks the expectTag tag of field f and reports
+// problems via addErrf. Returns false if the field is tagged "-".
+func checkFieldTag(
+	f *ast.Field,
+	expectTag, style, fieldName string,
+	promoted bool,
+	addErrf func(msg string, v ...any),
+) (decoded bool) {
+	embedded := len(f.Names) < 1
+	if f.Tag == nil || f.Tag.Value == "" {
+		if promoted {
+			// Fields of embedded structs are promoted.
+			return true
+		}
+		addErrf("missing tag %q", expectTag)
+		return true
+	}
+
+	tagContent, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		addErrf("unquoting tag: %v", err)
+	}
+
+	tags, err := structtag.Parse(tagContent)
+	if err != nil {
+		addErrf("parsing struct tags: %v", err)
+		return true
+	}
+	tag, err := tags.Get(expectTag)
+	if err != nil && expectTag == "env" {
+		if _, errPrefix := tags.Get("envPrefix"); errPrefix == nil {
+			// Fields of nested structs are prefixed.
+			return true
+		}
+	}
+	if err != nil {
+		if err.Error() == "tag does not exist" {
+			if promoted {
+				return true
+			}
+			addErrf("missing tag %q", expectTag)
+			return true
+		}
+		addErrf("getting tag %q: %v", expectTag, err)
+		return true
+	}
+	if tag.Name == "" {
+		if embedded && (promotesEmbedded(expectTag) || tag.HasOption("inline")) {
+			return true
+		}
+		if expectTag == "xml" && len(tag.Options) > 0 {
+			// Options such as ",attr" and ",chardata" default to the field name.
+			return true
+		}
+		if expectTag == "hcl" && tag.HasOption("block") {
+			// gohcl matches blocks by the type in the tag name.
+			addErrf("tag %q has option \",block\" but no block type", expectTag)
+			return true
+		}
+		if expectTag == "hcl" && (tag.HasOption("label") ||
+			tag.HasOption("remain") || tag.HasOption("body")) {
+			// Label names only appear in diagnostics,
+			// the remaining body and the body itself have no name.
+			return true
+		}
+		if len(tag.Options) > 0 {
+			// Likely meant to keep the default name, which is ambiguous
+			// across formats, or to skip the field, which requires "-".
+			addErrf("tag %q has options %q but no name",
+				expectTag, ","+strings.Join(tag.Options, ","))
+			return true
+		}
+		addErrf("tag %q is empty", expectTag)
+		return true
+	}
+	// "-" skips the field regardless of options such as "-,omitempty".
+	if style != "" && tag.Name != "-" {
+		if want := applyTagStyle(style, fieldName, expectTag); tag.Name != want {
+			addErrf("tag %q is %q, expected %q", expectTag, tag.Name, want)
+		}
+	}
+	return tag.Name != "-"
+}
+
+// anonymousStruct returns the anonymous struct type of field type expr,
+// or of the elements of the pointer, slice, array or map expr,
+// or nil if there is none.
+func anonymousStruct(expr ast.Expr) *ast.StructType {
+	for {
+		switch e := expr.(type) {
+		case *ast.StructType:
+			return e
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ArrayType:
+			expr = e.Elt
+		case *ast.MapType:
+			expr = e.Value
+		case *ast.ParenExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// applyTagFallback sets tag expectTag on all struct fields in pkg lacking it
+// to the value of the first tag in fallback they have.
+func applyTagFallback(pkg *ast.Package, expectTag string, fallback []string) error {
+	var err error
+	for _, k := range sortedKeys(pkg.Files) {
+		ast.Inspect(pkg.Files[k], func(n ast.Node) bool {
+			f, ok := n.(*ast.Field)
+			if !ok || f.Tag == nil || err != nil {
+				return err == nil
+			}
+			tagContent, errUnquote := strconv.Unquote(f.Tag.Value)
+			if errUnquote != nil {
+				return true
+			}
+			tags, errParse := structtag.Parse(tagContent)
+			if errParse != nil {
+				return true
+			}
+			if _, errGet := tags.Get(expectTag); errGet == nil {
+				return true
+			}
+			for _, key := range fallback {
+				t, errGet := tags.Get(key)
+				if errGet != nil {
+					continue
+				}
+				err = tags.Set(&structtag.Tag{
+					Key:     expectTag,
+					Name:    t.Name,
+					Options: t.Options,
+				})
+				f.Tag.Value = quoteTag(tags.String())
+				break
+			}
+			return true
+		})
+	}
+	return err
+}
+
+// applyEnvPrefixes replaces the env tag of struct-typed fields in pkg
+// with an envPrefix tag, such that the env tag of the field, for example
+// `env:"DB_"`, is prepended to the env tags of the fields of the struct.
+func applyEnvPrefixes(fset *token.FileSet, pkg *ast.Package) error {
+	var err error
+	for _, k := range sortedKeys(pkg.Files) {
+		ast.Inspect(pkg.Files[k], func(n ast.Node) bool {
+			f, ok := n.(*ast.Field)
+			if !ok || f.Tag == nil || err != nil {
+				return err == nil
+			}
+			if !isStructTypeExpr(fset, pkg, f.Type) {
+				return true
+			}
+			tagContent, errUnquote := strconv.Unquote(f.Tag.Value)
+			if errUnquote != nil {
+				return true
+			}
+			tags, errParse := structtag.Parse(tagContent)
+			if errParse != nil {
+				return true
+			}
+			t, errGet := tags.Get("env")
+			if errGet != nil || t.Name == "" || t.Name == "-" {
+				return true
+			}
+			if _, errGet := tags.Get("envPrefix"); errGet == nil {
+				return true
+			}
+			tags.Delete("env")
+			err = tags.Set(&structtag.Tag{Key: "envPrefix", Name: t.Name})
+			f.Tag.Value = quoteTag(tags.String())
+			return true
+		})
+	}
+	return err
+}
+
+// isStructTypeExpr returns true if e is a struct type
+// or a pointer to a struct type.
+func isStructTypeExpr(fset *token.FileSet, pkg *ast.Package, e ast.Expr) bool {
 	switch t := e.(type) {
-	case *ast.ChanType, *ast.FuncType:
+	case *ast.StarExpr:
+		return isStructTypeExpr(fset, pkg, t.X)
 	case *ast.StructType:
-		for _, f := range t.Fields.List {
-			traverseTypeIdents(fset, pkg, f.Type, fn)
-		}
-	case *ast.ArrayType:
-		traverseTypeIdents(fset, pkg, t.Elt, fn)
-	case *ast.MapType:
-		traverseTypeIdents(fset, pkg, t.Key, fn)
-		traverseTypeIdents(fset, pkg, t.Value, fn)
+		return true
 	case *ast.Ident:
-		id := e.(*ast.Ident)
-		if fn(id) {
-			return
-		}
-		if x := findType(fset, pkg, id.Name); x != nil {
-			traverseTypeIdents(fset, pkg, x.Type, fn)
+		if s := findType(fset, pkg, t.Name); s != nil {
+			_, ok := s.Type.(*ast.StructType)
+			return ok
 		}
 	}
+	return false
+}
+
+// embeddedFieldName returns the implicit name of an embedded field of type e.
+func embeddedFieldName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedFieldName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
+
+// promotesEmbedded returns true if the decoder of tag promotes the fields
+// of embedded structs without a tag. YAML requires the ",inline" option.
+func promotesEmbedded(tag string) bool {
+	switch tag {
+	case "json", "toml", "env", "xml":
+		return true
+	}
+	return false
+}
+
+// quoteTag returns tag as a Go string literal.
+func quoteTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
 }
 
 func isTypePrimitive(typeName string) bool {
@@ -587,20 +2460,28 @@ func unzipArchive(archive []byte, dst string) error {
 	return nil
 }
 
-func envToMap(envVars []string) map[string]string {
+// envToMap maps the names of envVars to their values. If prefix isn't empty,
+// variables not starting with prefix are dropped and prefix is stripped
+// from the names of the others.
+func envToMap(envVars []string, prefix string) map[string]string {
 	m := make(map[string]string, len(envVars))
 	for _, v := range envVars {
 		p := strings.SplitN(v, "=", 2)
 		if len(p) != 2 {
 			panic(fmt.Errorf("unexpected env var: %q", v))
 		}
-		m[p[0]] = p[1]
+		name, ok := strings.CutPrefix(p[0], prefix)
+		if !ok {
+			continue
+		}
+		m[name] = p[1]
 	}
 	return m
 }
 
 type InputType int8
 
+// Input types. New types are appended to keep the values of existing ones.
 const (
 	_ InputType = iota
 	InputTypeTOML
@@ -610,9 +2491,84 @@ const (
 	InputTypeENV
 	InputTypeDOTENV
 	InputTypeHCL
+	InputTypeENVRC
+	InputTypeXML
+	InputTypeINI
+	InputTypeCUE
+	InputTypeDHALL
 )
 
+// MarshalingTag returns the struct tag key used to decode the input type.
+func (t InputType) MarshalingTag() string {
+	switch t {
+	case InputTypeTOML:
+		return "toml"
+	case InputTypeJSON, InputTypeJSONNET, InputTypeCUE, InputTypeDHALL:
+		return "json"
+	case InputTypeYAML:
+		return "yaml"
+	case InputTypeENV, InputTypeDOTENV, InputTypeENVRC:
+		return "env"
+	case InputTypeHCL:
+		return "hcl"
+	case InputTypeXML:
+		return "xml"
+	case InputTypeINI:
+		return "ini"
+	}
+	return ""
+}
+
+// formatNames are the names of the input formats accepted by parseFormat.
+var formatNames = []string{
+	"toml", "json", "jsonnet", "yaml", "env", "dotenv", "envrc", "hcl", "xml", "ini",
+	"cue", "dhall",
+}
+
+// parseFormat returns the input type of the format name.
+func parseFormat(name string) (InputType, error) {
+	switch strings.ToLower(name) {
+	case "toml":
+		return InputTypeTOML, nil
+	case "json":
+		return InputTypeJSON, nil
+	case "jsonnet":
+		return InputTypeJSONNET, nil
+	case "yaml", "yml":
+		return InputTypeYAML, nil
+	case "env":
+		return InputTypeENV, nil
+	case "dotenv":
+		return InputTypeDOTENV, nil
+	case "envrc":
+		return InputTypeENVRC, nil
+	case "hcl":
+		return InputTypeHCL, nil
+	case "xml":
+		return InputTypeXML, nil
+	case "ini":
+		return InputTypeINI, nil
+	case "cue":
+		return InputTypeCUE, nil
+	case "dhall":
+		return InputTypeDHALL, nil
+	case "protojson", "prototext":
+		// Generated protobuf messages can't be decoded without their
+		// methods and registered descriptors, which aren't copied.
+		return 0, fmt.Errorf("unsupported format: %q, protobuf messages "+
+			"can't be validated", name)
+	}
+	return 0, fmt.Errorf("unsupported format: %q", name)
+}
+
 func getFileFormat(filePath string) (InputType, error) {
+	if isURL(filePath) {
+		filePath = urlPath(filePath)
+	}
+	if isGzip(filePath) {
+		// The format is detected by the extension of the compressed file.
+		filePath = strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	}
 	extension := strings.ToLower(filepath.Ext(filePath))
 	switch extension {
 	case ".toml":
@@ -625,8 +2581,19 @@ func getFileFormat(filePath string) (InputType, error) {
 		return InputTypeYAML, nil
 	case ".hcl":
 		return InputTypeHCL, nil
+	case ".xml":
+		return InputTypeXML, nil
+	case ".ini":
+		return InputTypeINI, nil
+	case ".cue":
+		return InputTypeCUE, nil
+	case ".dhall":
+		return InputTypeDHALL, nil
 	}
 	fileName := filepath.Base(filePath)
+	if fileName == ".envrc" {
+		return InputTypeENVRC, nil
+	}
 	if regexEnvFile.MatchString(fileName) {
 		return InputTypeDOTENV, nil
 	}
@@ -635,10 +2602,20 @@ func getFileFormat(filePath string) (InputType, error) {
 
 var regexEnvFile = regexp.MustCompile(`^\.env(\..+)?$`)
 
-func sortedKeys[K comparable, V any](m map[K]V) []K {
+func sortedValues[K comparable, V cmp.Ordered](m map[K]V) []V {
+	s := make([]V, 0, len(m))
+	for _, v := range m {
+		s = append(s, v)
+	}
+	slices.Sort(s)
+	return s
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
 	s := make([]K, 0, len(m))
 	for k := range m {
 		s = append(s, k)
 	}
+	slices.Sort(s)
 	return s
 }
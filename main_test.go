@@ -1,7 +1,10 @@
-package main
+package valfile
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +14,11 @@ import (
 )
 
 func TestCLI(t *testing.T) {
+	// Programs are cached across test cases but not across test runs.
+	cacheDir := t.TempDir()
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+	defer func() { userCacheDir = os.UserCacheDir }()
+
 	for _, td := range []Test{
 		// CLI Parameters
 		{
@@ -19,89 +27,3344 @@ func TestCLI(t *testing.T) {
 			EnvVars: []string{"FOO=bar"},
 			Files: map[string]string{
 				"tstcmd/main.go": `
-					package main; type Config struct { Foo "env:\"FOO\"" }
+					package main; type Config struct { Foo "env:\"FOO\"" }
+				`,
+			},
+			ExpectErrs: []string{"missing type name"},
+		},
+		{
+			Name:    "err_missing_tag_env",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"FOO=bar"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+			},
+			ExpectErrs: []string{`Config.Foo: missing tag "env"`},
+		},
+		{
+			Name:    "err_missing_tag_json",
+			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			EnvVars: []string{"FOO=bar"},
+			Files: map[string]string{
+				"input.json":     `{"foo":"bar"}`,
+				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+			},
+			ExpectErrs: []string{`Config.Foo: missing tag "json"`},
+		},
+		{
+			Name:    "err_missing_tag_toml",
+			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
+			EnvVars: []string{`foo="bar"`},
+			Files: map[string]string{
+				"input.toml":     `{"foo":"bar"}`,
+				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+			},
+			ExpectErrs: []string{`Config.Foo: missing tag "toml"`},
+		},
+		{
+			Name:    "err_missing_tag_jsonnet",
+			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
+			EnvVars: []string{`{foo:"bar"}`},
+			Files: map[string]string{
+				"input.jsonnet":  `{"foo":"bar"}`,
+				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+			},
+			ExpectErrs: []string{`Config.Foo: missing tag "json"`},
+		},
+		{
+			Name:    "err_missing_tag_hcl",
+			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			EnvVars: []string{`{foo:"bar"}`},
+			Files: map[string]string{
+				"input.hcl":      `foo="bar"`,
+				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+			},
+			ExpectErrs: []string{`Config.Foo: missing tag "hcl"`},
+		},
+		{
+			Name: "err_archive_missing_entry",
+			Args: "-p $SETUP/tstcmd -t Config -archive $SETUP/bundle.zip",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main`,
+			},
+			ExpectErrs: []string{"missing archive entry"},
+		},
+		{
+			Name:       "err_effective_format_without_print_effective",
+			Args:       "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -effective-format json",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-effective-format requires -print-effective"},
+		},
+		{
+			Name: "err_report_defaults_file_without_report_defaults",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-report-defaults-file $SETUP/defaults.json",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-report-defaults-file requires -report-defaults"},
+		},
+		{
+			Name: "err_report_defaults_print_effective",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-report-defaults json -print-effective",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-report-defaults requires -report-defaults-file " +
+				"with -print-effective, both write to stdout"},
+		},
+		{
+			Name: "err_effective_format_unsupported",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml " +
+				"-print-effective -effective-format toml",
+			Files: map[string]string{
+				"input.yaml":     "port: 80\n",
+				"tstcmd/main.go": `package main; type Config struct { Port int "yaml:\"port\"" }`,
+			},
+			ExpectErrs: []string{
+				"-effective-format toml is only supported for inputs of the same format",
+			},
+		},
+		{
+			Name:       "err_report_defaults_format",
+			Args:       "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -report-defaults csv",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{`unsupported defaults report format: "csv"`},
+		},
+
+		// Tag lint
+		{
+			Name: "err_tag_style",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -tag-style snake",
+			Files: map[string]string{
+				"input.yaml": "max_conns: 1\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						MaxConns int    "yaml:\"max_conns\""
+						UserID   string "yaml:\"userId\""
+						Ignored  string "yaml:\"-\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.UserID: tag "yaml" is "userId", expected "user_id"`},
+		},
+		{
+			Name: "err_lint_tags_style",
+			Args: "-lint-tags -p $SETUP/tstcmd -format env -tag-style snake",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { DBHost string "env:\"DB_HOSTNAME\"" }
+				`,
+			},
+			ExpectErrs: []string{`Config.DBHost: tag "env" is "DB_HOSTNAME", expected "DB_HOST"`},
+		},
+		{
+			Name:       "err_unsupported_tag_style",
+			Args:       "-lint-tags -p $SETUP/tstcmd -format json -tag-style pascal",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{`unsupported tag style: "pascal"`},
+		},
+		{
+			Name: "err_lint_tags",
+			Args: "-lint-tags -p $SETUP/tstcmd -format json",
+			Files: map[string]string{
+				"tstcmd/a.go": `package main
+					type Config struct {
+						Foo string "json:\"foo\""
+						Bar string
+					}
+					type unexported struct { Baz string }
+				`,
+				"tstcmd/b.go": `package main
+					type Other struct { Qux string "yaml:\"qux\"" }
+					type Name string
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Bar: missing tag "json"`,
+				`Other.Qux: missing tag "json"`,
+			},
+		},
+		{
+			Name:  "err_format_protojson",
+			Args:  "-lint-tags -p $SETUP/tstcmd -format protojson",
+			Files: map[string]string{"tstcmd/a.go": `package main`},
+			ExpectErrs: []string{`invalid value "protojson" for flag -format: ` +
+				`unsupported format: "protojson", protobuf messages can't be validated`},
+		},
+		{
+			Name:       "err_lint_tags_missing_format",
+			Args:       "-lint-tags -p $SETUP/tstcmd",
+			Files:      map[string]string{"tstcmd/a.go": `package main`},
+			ExpectErrs: []string{"missing input format, use -format"},
+		},
+		{
+			Name: "err_require_tag_on_all",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -require-tag-on-all",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Base struct { Region string "json:\"region\"" }
+					type Config struct {
+						Base
+						Server struct {
+							Listeners []struct {
+								TLS map[string]*struct {
+									Cert string "json:\"cert\""
+									Key  string
+								} "json:\"tls\""
+							} "json:\"listeners\""
+						} "json:\"server\""
+						Ignored struct { Foo string } "json:\"-\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Base: missing tag "json"`,
+				`Config.Server.Listeners.TLS.Key: missing tag "json"`,
+			},
+		},
+		{
+			Name: "require_tag_on_all_embedded",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -require-tag-on-all",
+			Files: map[string]string{
+				"input.json": `{"region":"eu","nested":{"deep":{"leaf":1}}}`,
+				"tstcmd/main.go": `package main
+					type Base struct { Region string "json:\"region\"" }
+					type Config struct {
+						Base   "json:\",omitempty\""
+						Nested Nested "json:\"nested\""
+					}
+					type Nested struct {
+						Deep struct { Leaf int "json:\"leaf\"" } "json:\"deep\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_lint_tags_require_tag_on_all",
+			Args: "-lint-tags -p $SETUP/tstcmd -format yaml -require-tag-on-all",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						A struct {
+							B struct {
+								C struct { D int } "yaml:\"c\""
+							} "yaml:\"b\""
+						} "yaml:\"a\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.A.B.C.D: missing tag "yaml"`},
+		},
+		{
+			Name: "compile_check",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format yaml",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					import "time"
+					type Config struct {
+						Timeout time.Duration     "yaml:\"timeout\""
+						Servers map[string]Server "yaml:\"servers\""
+					}
+					type Server struct { Port int "yaml:\"port\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_compile_check_tags",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{`Config.Port: missing tag "json"`},
+		},
+		{
+			Name:       "err_compile_check_missing_format",
+			Args:       "-compile-check -p $SETUP/tstcmd -t Config",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"missing input format, use -format"},
+		},
+		{
+			Name: "no_cache",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -no-cache",
+			Files: map[string]string{
+				"input.json":     `{"port": 80}`,
+				"tstcmd/main.go": `package main; type Config struct { Port int "json:\"port\"" }`,
+			},
+		},
+		{
+			Name: "err_no_cache",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -no-cache",
+			Files: map[string]string{
+				"input.json":     `{"host": "x"}`,
+				"tstcmd/main.go": `package main; type Config struct { Port int "json:\"port\"" }`,
+			},
+			ExpectErrs: []string{`json: unknown field "host"`},
+		},
+		{
+			Name: "validate_tags",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -validate",
+			Files: map[string]string{
+				"input.json": `{"port": 80}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int "json:\"port\" validate:\"min=1,max=65535\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_validate_tags",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json -validate",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int "json:\"port\" validate:\"min=1,,max=65535\""
+						TLS  *struct {
+							Cert string "json:\"cert\" validate:\"required|\""
+						} "json:\"tls\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Port: invalid validate tag "min=1,,max=65535": empty rule`,
+				`Config.TLS.Cert: invalid validate tag "required|": empty rule`,
+			},
+		},
+		{
+			Name: "require_validate",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -require-validate",
+			Files: map[string]string{
+				"input.json": `{"port": 80, "name": "x"}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Base
+						Port int    "json:\"port\" validate:\"min=1\""
+						Name string "json:\"name\" validate:\"-\""
+						note string "json:\"-\""
+					}
+					type Base struct {
+						ID string "json:\"id\" validate:\"omitempty,uuid\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_require_validate",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json " +
+				"-require-validate",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "json:\"port\" validate:\"min=1\""
+						Host string "json:\"host\""
+						Name string
+						TLS  struct {
+							Cert string "json:\"cert\""
+						} "json:\"tls\" validate:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Name: missing tag "json"`,
+				`Config.Host: missing tag "validate"`,
+				`Config.Name: missing tag "validate"`,
+				`Config.TLS.Cert: missing tag "validate"`,
+			},
+		},
+		{
+			Name: "err_duplicate_tags",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						A     string "json:\"name\" yaml:\"a\""
+						B     string "json:\"name,omitempty\" yaml:\"a\""
+						C     string "json:\"-\""
+						D     string "json:\"-\""
+						Inner struct {
+							A string "json:\"name\""
+							B string "json:\"name\""
+						} "json:\"inner\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.B: duplicate json tag "name" (also on Config.A)`,
+				`Config.Inner.B: duplicate json tag "name" (also on Config.Inner.A)`,
+			},
+		},
+		{
+			Name: "err_duplicate_tags_xml",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format xml " +
+				"-require-tag-on-all",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						ID    string "xml:\"id,attr\""
+						IDs   string "xml:\"id\""
+						Inner struct {
+							A string "xml:\"name\""
+							B string "xml:\"name\""
+						} "xml:\"inner\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Inner.B: duplicate xml tag "name" (also on Config.Inner.A)`,
+			},
+		},
+		{
+			Name: "err_tag_options_without_name",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						A string "json:\",omitempty\""
+						B string "json:\"\""
+						C string "json:\"-\""
+						D string "json:\"-,omitempty\""
+						E string "json:\"e,omitempty\""
+						F string "json:\",omitempty,string\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.A: tag "json" has options ",omitempty" but no name`,
+				`Config.B: tag "json" is empty`,
+				`Config.F: tag "json" has options ",omitempty,string" but no name`,
+			},
+		},
+		{
+			Name: "err_anonymous_struct_tags",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Metrics struct {
+							Port int
+							Path string "json:\"path\""
+						} "json:\"metrics\""
+						Servers []*struct {
+							Host string
+						} "json:\"servers\""
+						Skipped struct { Port int } "json:\"-\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Metrics.Port: missing tag "json"`,
+				`Config.Servers.Host: missing tag "json"`,
+			},
+		},
+		{
+			Name: "err_compile_check_conflict",
+			Args: "-compile-check -p $SETUP/tstcmd -t Config -format json " +
+				"-f $SETUP/input.json",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"conflicting parameters, -compile-check " +
+				"is mutually exclusive with -f, -archive, -env, -set, -merge-patch, " +
+				"-input-dir and -config"},
+		},
+		{
+			Name: "err_require_tag_on_all_conflict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-require-tag-on-all -no-tag-check",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"conflicting parameters, " +
+				"-require-tag-on-all and -no-tag-check are mutually exclusive"},
+		},
+
+		// Type resolution
+		{
+			Name: "err_dot_import_non_std",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"foo":"bar"}`,
+				"tstcmd/main.go": `package main
+					import . "example.com/foo"
+					type Config struct { Foo Foo "json:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`undefined type: Foo: identifier comes from dot-imported package ` +
+					`"example.com/foo", dot-imports of packages outside ` +
+					`the standard library are not supported`,
+			},
+		},
+
+		{
+			Name: "err_recursive_type",
+			Args: "-p $SETUP/tstcmd -t Tree -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"name":"root","children":[{"children":[{"name":"x"}]}]}`,
+				"tstcmd/main.go": `package main
+					type Tree struct {
+						Name     string "json:\"name\" valfile:\"required\""
+						Children []Tree "json:\"children\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Tree.Children[0].Name: missing required field "name"`},
+		},
+		{
+			Name: "required_pointers",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"port":0,"tls":{"cert":""},"timeout":null}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port    *int       "json:\"port\" yaml:\"port\" valfile:\"required\""
+						TLS     *TLSConfig "json:\"tls\" yaml:\"tls\" valfile:\"required\""
+						Timeout *int       "json:\"timeout\" yaml:\"timeout\""
+					}
+					type TLSConfig struct {
+						Cert *string "json:\"cert\" yaml:\"cert\" valfile:\"required\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_required_pointers_null",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"port":null,"tls":{"cert":null}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port    *int       "json:\"port\" yaml:\"port\" valfile:\"required\""
+						TLS     *TLSConfig "json:\"tls\" yaml:\"tls\" valfile:\"required\""
+						Timeout *int       "json:\"timeout\" yaml:\"timeout\""
+					}
+					type TLSConfig struct {
+						Cert *string "json:\"cert\" yaml:\"cert\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Port: required field "port" is null`,
+				`Config.TLS.Cert: required field "cert" is null`,
+			},
+		},
+		{
+			Name: "err_required_pointers_null_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "port: 8080\ntls: ~\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port    *int       "json:\"port\" yaml:\"port\" valfile:\"required\""
+						TLS     *TLSConfig "json:\"tls\" yaml:\"tls\" valfile:\"required\""
+						Timeout *int       "json:\"timeout\" yaml:\"timeout\""
+					}
+					type TLSConfig struct {
+						Cert *string "json:\"cert\" yaml:\"cert\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.TLS: required field "tls" is null`},
+		},
+		{
+			Name: "pointer_types",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"sub":{"name":"s"},"items":[{"name":"i"},null],` +
+					`"things":{"a":{"name":"t"}}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Sub    *SubConfig        "json:\"sub\""
+						Items  []*Item           "json:\"items\""
+						Things map[string]*Thing "json:\"things\""
+					}
+					type SubConfig struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+					type Item struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+					type Thing struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_pointer_types",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"sub":{},"items":[{"name":"i"},{}],"things":{"a":{}}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Sub    *SubConfig        "json:\"sub\""
+						Items  []*Item           "json:\"items\""
+						Things map[string]*Thing "json:\"things\""
+					}
+					type SubConfig struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+					type Item struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+					type Thing struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Sub.Name: missing required field "name"`,
+				`Config.Items[1].Name: missing required field "name"`,
+				`Config.Things[a].Name: missing required field "name"`,
+			},
+		},
+
+		// Union of types
+		{
+			Name: "err_any",
+			Args: "-p $SETUP/tstcmd -t ConfigV1 -t ConfigV2 -any -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"listen":"x:80"}`,
+				"tstcmd/main.go": `package main
+					type ConfigV1 struct { Addr string "json:\"addr\"" }
+					type ConfigV2 struct { Listen int "json:\"listen\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"input matches none of the types: ConfigV1, ConfigV2",
+				`ConfigV1: json: unknown field "listen"`,
+				"ConfigV2: json: cannot unmarshal string into Go struct field " +
+					"ConfigV2.listen of type int",
+			},
+		},
+		{
+			Name:       "err_multiple_types_without_any",
+			Args:       "-p $SETUP/tstcmd -t ConfigV1 -t ConfigV2 -f $SETUP/input.json",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"multiple types require -any"},
+		},
+
+		// Root fields
+		{
+			Name: "err_root_field",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"host":"localhost","port":0}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name   string
+						Server Server "valfile:\"root\""
+					}
+					type Server struct {
+						Host string "json:\"host\""
+						Port int    "json:\"port\" validate:\"gt=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"Key: 'Server.Port' Error:Field validation " +
+				"for 'Port' failed on the 'gt' tag"},
+		},
+		{
+			Name: "err_root_field_multiple",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						A Server "valfile:\"root\""
+						B Server "valfile:\"root\""
+					}
+					type Server struct{}
+				`,
+			},
+			ExpectErrs: []string{`Config: multiple fields tagged valfile:"root"`},
+		},
+		{
+			Name: "err_root_field_not_declared",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Servers []string "valfile:\"root\"" }
+				`,
+			},
+			ExpectErrs: []string{`Config.Servers: field tagged valfile:"root" ` +
+				"must be of a type declared in the package"},
+		},
+
+		// Kinds
+		{
+			Name: "err_kinds",
+			Args: "-p $SETUP/tstcmd -kind Server=ServerConfig -kind DB=DBConfig " +
+				"-f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "kind: Server\nport: 80\n---\n" +
+					"kind: DB\nport: 80\n---\n" +
+					"kind: Cache\n---\n" +
+					"dsn: x\n",
+				"tstcmd/main.go": `package main
+					type ServerConfig struct {
+						Kind string "yaml:\"kind\""
+						Port int    "yaml:\"port\""
+					}
+					type DBConfig struct {
+						Kind string "yaml:\"kind\""
+						DSN  string "yaml:\"dsn\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"document 1: yaml: unmarshal errors:\n" +
+					"  line 2: field port not found in type main.DBConfig",
+				`document 2: unknown kind "Cache"`,
+				`document 3: missing field "kind"`,
+			},
+		},
+		{
+			Name:  "err_kinds_json",
+			Args:  "-p $SETUP/tstcmd -kind Server=ServerConfig -f $SETUP/input.json",
+			Files: map[string]string{"input.json": `{}`, "tstcmd/main.go": `package main`},
+			ExpectErrs: []string{
+				"-kind is only supported for YAML input",
+			},
+		},
+
+		// Fields
+		{
+			Name: "fields",
+			Args: "-p $SETUP/tstcmd -field server=ServerConfig " +
+				"-field database=databaseConfig -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "server:\n  port: 80\ndatabase:\n  dsn: x\n",
+				"tstcmd/main.go": `package main
+					type Input struct{}
+					type ServerConfig struct { Port int "yaml:\"port\"" }
+					type databaseConfig struct { DSN string "yaml:\"dsn\"" }
+				`,
+			},
+		},
+		{
+			Name: "fields_toml",
+			Args: "-p $SETUP/tstcmd -field server=ServerConfig " +
+				"-field database=DatabaseConfig -f $SETUP/input.toml",
+			Files: map[string]string{
+				"input.toml": "[server]\nport = 80\n[database]\ndsn = \"x\"\n",
+				"tstcmd/main.go": `package main
+					type ServerConfig struct { Port int "toml:\"port\"" }
+					type DatabaseConfig struct { DSN string "toml:\"dsn\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_fields",
+			Args: "-p $SETUP/tstcmd -field server=ServerConfig " +
+				"-field database=DatabaseConfig -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "server:\n  port: 80\n  host: x\ndatabase:\n  dsn: x\n",
+				"tstcmd/main.go": `package main
+					type ServerConfig struct {
+						Port int    "yaml:\"port\""
+						Host string "yaml:\"host\" validate:\"ip\""
+					}
+					type DatabaseConfig struct { DSN string "yaml:\"dsn\" validate:\"url\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"Key: 'Input.DatabaseConfig.DSN' Error:Field validation for 'DSN' " +
+					"failed on the 'url' tag\n" +
+					"Key: 'Input.ServerConfig.Host' Error:Field validation for 'Host' " +
+					"failed on the 'ip' tag",
+			},
+		},
+		{
+			Name: "err_fields_tags",
+			Args: "-p $SETUP/tstcmd -field server=ServerConfig " +
+				"-field database=DatabaseConfig -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type ServerConfig struct { Port int "json:\"port\"" }
+					type DatabaseConfig struct { DSN string "yaml:\"dsn\"" }
+				`,
+			},
+			ExpectErrs: []string{`DatabaseConfig.DSN: missing tag "json"`},
+		},
+		{
+			Name: "err_fields_conflict",
+			Args: "-p $SETUP/tstcmd -t Config -field server=ServerConfig " +
+				"-f $SETUP/input.json",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{
+				"conflicting parameters, -field is mutually exclusive with -t and -kind",
+			},
+		},
+		{
+			Name:  "err_fields_mapping",
+			Args:  "-p $SETUP/tstcmd -field server -f $SETUP/input.json",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{`invalid value "server" for flag -field: ` +
+				`invalid field mapping "server", expected key=Type`},
+		},
+		{
+			Name:       "err_fields_env",
+			Args:       "-p $SETUP/tstcmd -field server=ServerConfig -env",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-field isn't supported for environment variables"},
+		},
+
+		// Archive
+		{
+			Name: "err_archive_entry",
+			Args: "-p $SETUP/tstcmd -t Config " +
+				"-archive $SETUP/bundle.tar.gz -entry conf/prod.yaml",
+			Files: map[string]string{
+				"bundle.tar.gz": makeTarGz(map[string]string{
+					"conf/prod.yaml": "bar: baz\n",
+				}),
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "yaml:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{"yaml: unmarshal errors:\n" +
+				"  line 1: field bar not found in type main.Config"},
+		},
+
+		// CUE
+		{
+			Name: "cue",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.cue",
+			Files: map[string]string{
+				"input.cue": `
+					#Port: int & >1024
+					port: #Port & 8080
+					host: "localhost"
+					url:  "http://\(host):\(port)"
+				`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "json:\"port\""
+						Host string "json:\"host\""
+						URL  string "json:\"url\" validate:\"url\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_cue",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.cue",
+			Files: map[string]string{
+				"input.cue": `port: 0, debug: true`,
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\" validate:\"gt=0\"" }
+				`,
+			},
+			ExpectErrs: []string{`json: unknown field "debug"`},
+		},
+		{
+			Name: "err_cue_conflict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.cue",
+			Files: map[string]string{
+				"input.cue": "port: 80\nport: 8080\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{"evaluating CUE: port: conflicting values 8080 and 80:\n" +
+				"    $SETUP/input.cue:1:7\n    $SETUP/input.cue:2:7"},
+		},
+		{
+			Name: "err_cue_incomplete",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.cue",
+			Files: map[string]string{
+				"input.cue": "port: int\nhost: string\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "json:\"port\""
+						Host string "json:\"host\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"evaluating CUE: host: incomplete value string:\n" +
+				"    $SETUP/input.cue:2:7\n" +
+				"port: incomplete value int:\n" +
+				"    $SETUP/input.cue:1:7"},
+		},
+
+		// Dhall
+		{
+			Name: "dhall",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.dhall",
+			Files: map[string]string{
+				"input.dhall": `
+					let host = "localhost"
+					let port = 8080
+					in  { host, port, url = "http://${host}:${Natural/show port}" }
+				`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Host string "json:\"host\""
+						Port int    "json:\"port\""
+						URL  string "json:\"url\" validate:\"url\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_dhall",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.dhall",
+			Files: map[string]string{
+				"input.dhall": `{ port = 0 }`,
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\" validate:\"gt=0\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "err_dhall_type",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.dhall",
+			Files: map[string]string{
+				"input.dhall": `{ port = 80 + "1" }`,
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{"evaluating Dhall: ❰+❱ only works on ❰Natural❱s"},
+		},
+
+		// gzip
+		{
+			Name: "gzip",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/app.json.gz",
+			Files: map[string]string{
+				"app.json.gz": makeGzip(`{"foo":"bar"}`),
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_gzip",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/app.yaml.GZ",
+			Files: map[string]string{
+				"app.yaml.GZ": makeGzip("bar: baz\n"),
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "yaml:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{"yaml: unmarshal errors:\n" +
+				"  line 1: field bar not found in type main.Config"},
+		},
+		{
+			Name: "err_gzip_corrupt",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/app.json.gz",
+			Files: map[string]string{
+				"app.json.gz": `{"foo":"bar"}`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{"reading input file: reading gzip: gzip: invalid header"},
+		},
+		{
+			Name: "err_gzip_unsupported",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/app.gz",
+			Files: map[string]string{
+				"app.gz":         makeGzip(`{"foo":"bar"}`),
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{`unsupported file type: "app"` + "\n"},
+		},
+
+		// XML
+		{
+			Name: "xml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml",
+			Files: map[string]string{
+				"input.xml": `<config version="2" xmlns:x="urn:x">
+					<name>app</name>
+					<server port="80"><host>a</host></server>
+					<server port="81"><host>b</host></server>
+					<tags><tag>x</tag><tag>y</tag></tags>
+					<timeout>2s</timeout>
+				</config>`,
+				"tstcmd/main.go": `package main
+					import "encoding/xml"
+					type Base struct { Name string "xml:\"name\" validate:\"required\"" }
+					type Config struct {
+						XMLName xml.Name  "xml:\"config\""
+						Version int       "xml:\"version,attr\""
+						Base
+						Servers []Server  "xml:\"server\""
+						Tags    []string  "xml:\"tags>tag\""
+						Timeout Duration  "xml:\"timeout\""
+					}
+					type Server struct {
+						Port int    "xml:\"port,attr\""
+						Host string "xml:\"host\""
+					}
+					type Duration string
+				`,
+			},
+		},
+		{
+			Name: "err_xml_unknown",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml",
+			Files: map[string]string{
+				"input.xml": `<config debug="true">
+					<server port="80" tls="on"><host>a</host><hots>b</hots></server>
+					<server port="81"><host>b</host></server>
+					<server port="82"><x/></server>
+					<extra/>
+				</config>`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Servers []Server "xml:\"server\""
+					}
+					type Server struct {
+						Port int    "xml:\"port,attr\""
+						Host string "xml:\"host\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config: unknown attribute "debug"`,
+				`Config.Servers[0]: unknown attribute "tls"`,
+				`Config.Servers[0]: unknown element "hots"`,
+				`Config.Servers[2]: unknown element "x"`,
+				`Config: unknown element "extra"`,
+			},
+		},
+		{
+			Name:  "stdin",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin -format json",
+			Stdin: `{"name":"app"}`,
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name:  "err_stdin",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin -format yaml",
+			Stdin: "name: app\nport: 80\n",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "yaml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"yaml: unmarshal errors:\n  line 2: field port not found in type main.Config",
+			},
+		},
+		{
+			Name: "err_stdin_empty",
+			Args: "-p $SETUP/tstcmd -t Config -stdin -format json",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"standard input is empty"},
+		},
+		{
+			Name:  "err_stdin_missing_format",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin",
+			Stdin: `{}`,
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct{}`,
+			},
+			ExpectErrs: []string{"missing input format, use -format"},
+		},
+		{
+			Name:  "err_stdin_unknown_format",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin -format proto",
+			Stdin: `{}`,
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct{}`,
+			},
+			ExpectErrs: []string{
+				`invalid value "proto" for flag -format: unsupported format: "proto"`,
+			},
+		},
+		{
+			Name:  "err_stdin_conflict",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin -format json -f $SETUP/input.json",
+			Stdin: `{}`,
+			Files: map[string]string{
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct{}`,
+			},
+			ExpectErrs: []string{
+				"conflicting parameters, -stdin is mutually exclusive with " +
+					"-f, -archive, -env, -set and -merge-patch",
+			},
+		},
+		{
+			Name:  "err_stdin_watch",
+			Args:  "-p $SETUP/tstcmd -t Config -stdin -format json -watch",
+			Stdin: `{}`,
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct{}`,
+			},
+			ExpectErrs: []string{
+				"conflicting parameters, -watch is mutually exclusive with " +
+					"-stdin, -explain-type and -emit-schema",
+			},
+		},
+		{
+			Name: "ini",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": `name = app
+; comment
+[server]
+host = localhost
+port = 8080
+
+[log]
+level = debug
+`,
+				"tstcmd/main.go": `package main
+					import "time"
+					type Config struct {
+						Name    string     "ini:\"name\""
+						Server  Server     "ini:\"server\""
+						Log     *Log       "ini:\"log\""
+						Metrics *Metrics   "ini:\"metrics\""
+						Started time.Time  "ini:\"started\""
+					}
+					type Server struct {
+						Host string "ini:\"host\" validate:\"required\""
+						Port int    "ini:\"port\""
+					}
+					type Log struct {
+						Level string "ini:\"level\""
+					}
+					type Metrics struct {
+						Addr string "ini:\"addr\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_ini_unknown",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": `name = app
+debug = true
+[server]
+host = localhost
+prot = 8080
+[extra]
+x = 1
+`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name   string "ini:\"name\""
+						Server Server "ini:\"server\""
+					}
+					type Server struct {
+						Host string "ini:\"host\""
+						Port int    "ini:\"port\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Server: unknown key "prot"`,
+				`Config: unknown key "debug"`,
+				`Config: unknown section "extra"`,
+			},
+		},
+		{
+			Name: "err_ini_missing_section",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": "name = app\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name   string "ini:\"name\""
+						Server Server "ini:\"server\""
+					}
+					type Server struct {
+						Host string "ini:\"host\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Server: missing section "server"`,
+			},
+		},
+		{
+			Name: "err_ini",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": "[server]\nport = abc\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "ini:\"server\""
+					}
+					type Server struct {
+						Port int "ini:\"port\" valfile:\"required\""
+						Host string "ini:\"host\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`map to field "server": set field "port": ` +
+					`strconv.ParseInt: parsing "abc": invalid syntax`,
+			},
+		},
+		{
+			Name: "err_ini_required",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": "[server]\nport = 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "ini:\"server\""
+					}
+					type Server struct {
+						Port int "ini:\"port\" valfile:\"required\""
+						Host string "ini:\"host\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Server.Host: missing required field "host"`,
+			},
+		},
+		{
+			Name: "err_ini_tags",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.ini",
+			Files: map[string]string{
+				"input.ini": "name = app\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "json:\"name\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Name: missing tag "ini"`,
+			},
+		},
+		{
+			Name: "err_xml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml",
+			Files: map[string]string{
+				"input.xml": "<config>\n<port>x</port>\n</config>",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "xml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{`strconv.ParseInt: parsing "x": invalid syntax`},
+		},
+		{
+			Name: "err_xml_syntax",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml",
+			Files: map[string]string{
+				"input.xml": "<config>\n<port>1</prt>\n</config>",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "xml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{"XML syntax error on line 2: " +
+				"element <port> closed by </prt>"},
+		},
+		{
+			Name: "err_xml_tags",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml",
+			Files: map[string]string{
+				"input.xml": "<config/>",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int "json:\"port\""
+						Host string "xml:\",chardata\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Port: missing tag "xml"`},
+		},
+
+		// Multiple input files
+		{
+			Name: "err_multiple_files",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/a.json -f $SETUP/b.toml " +
+				"-f $SETUP/c.json",
+			Files: map[string]string{
+				"a.json": `{"port":80,"host":"x"}`,
+				"b.toml": "port = 80\nhost = \"x\"\n",
+				"c.json": `{"port":"80"}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "json:\"port\" toml:\"port\""
+						Host string "json:\"host\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`$SETUP/b.toml: Config.Host: missing tag "toml"`,
+				"$SETUP/c.json: json: cannot unmarshal string into Go struct " +
+					"field Config.port of type int",
+			},
+		},
+		{
+			Name: "multiple_files",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/a.json -f $SETUP/b.toml",
+			Files: map[string]string{
+				"a.json": `{"port":80}`,
+				"b.toml": "port = 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\" toml:\"port\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_multiple_files_comma",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/a,b.yaml -f $SETUP/c.yaml",
+			Files: map[string]string{
+				"a,b.yaml": "port: x\n",
+				"c.yaml":   "port: 81\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"$SETUP/a,b.yaml: yaml: unmarshal errors:\n" +
+					"  line 1: cannot unmarshal !!str `x` into int",
+			},
+		},
+		{
+			Name: "err_glob",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/configs/*",
+			Files: map[string]string{
+				"configs/a.yaml": "port: 80\n",
+				"configs/b.json": `{"port":"x"}`,
+				"configs/c.yml":  "port: 81\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "json:\"port\" yaml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"$SETUP/configs/b.json: json: cannot unmarshal string into Go struct " +
+					"field Config.port of type int",
+			},
+		},
+		{
+			Name: "glob_single",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/configs/*.yaml",
+			Files: map[string]string{
+				"configs/a.yaml": "port: 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_glob_no_match",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/configs/*.toml",
+			Files: map[string]string{
+				"configs/a.yaml": "port: 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`invalid value "$SETUP/configs/*.toml" for flag -f: ` +
+					`no input files match "$SETUP/configs/*.toml"`,
+			},
+		},
+		{
+			Name: "err_multiple_files_sha256",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/a.json -f $SETUP/b.toml " +
+				"-expect-sha256 " + strings.Repeat("0", 64),
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-expect-sha256 requires a single input file"},
+		},
+
+		// Input directory
+		{
+			Name: "err_input_dir",
+			Args: "-p $SETUP/tstcmd -input-dir $SETUP/configs " +
+				"-map *.yaml=Config -map *.json=Secrets",
+			Files: map[string]string{
+				"configs/a.yaml":     "port: 80\n",
+				"configs/b.json":     `{"token":"x","port":80}`,
+				"configs/sub/c.yaml": "host: x\n",
+				"configs/README.md":  "ignored",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\"" }
+					type Secrets struct { Token string "json:\"token\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`$SETUP/configs/b.json: json: unknown field "port"`,
+				"$SETUP/configs/sub/c.yaml: yaml: unmarshal errors:\n" +
+					"  line 1: field host not found in type main.Config",
+			},
+		},
+		{
+			Name: "err_input_dir_no_match",
+			Args: "-p $SETUP/tstcmd -input-dir $SETUP/configs -map *.toml=Config",
+			Files: map[string]string{
+				"configs/a.yaml": "port: 80\n",
+				"tstcmd/main.go": `package main`,
+			},
+			ExpectErrs: []string{
+				"no files in $SETUP/configs match any of the type mappings",
+			},
+		},
+		{
+			Name: "err_report_unused",
+			Args: "-p $SETUP/tstcmd -input-dir $SETUP/configs " +
+				"-map *.yaml=Config -report-unused",
+			Files: map[string]string{
+				"configs/a.yaml": "port: 80\ndb: {host: x}\n",
+				"configs/b.yaml": "port: 81\ndebug: true\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port   int  "yaml:\"port\""
+						Debug  bool "yaml:\"debug\""
+						Legacy bool "yaml:\"legacy\""
+						DB     DB   "yaml:\"db\""
+						Cache  DB   "yaml:\"cache\""
+					}
+					type DB struct {
+						Host string "yaml:\"host\""
+						Pool int    "yaml:\"pool\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"warning: Config.Cache: not set by any input",
+				"warning: Config.DB.Pool: not set by any input",
+				"warning: Config.Legacy: not set by any input",
+			},
+		},
+
+		{
+			Name: "err_warn_zero",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -warn-zero",
+			Files: map[string]string{
+				"input.yaml": "name: x\ntimeout: 0\nservers: [{host: a}]\n" +
+					"labels: {b: {port: 1}}\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Base    "yaml:\",inline\""
+						Name    string            "yaml:\"name\""
+						Timeout int               "yaml:\"timeout\""
+						Servers []Server          "yaml:\"servers\""
+						Labels  map[string]Server "yaml:\"labels\""
+						Ignored int               "yaml:\"-\""
+					}
+					type Base struct { ID int "yaml:\"id\"" }
+					type Server struct {
+						Host string "yaml:\"host\""
+						Port int    "yaml:\"port\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"warning: Config.ID: zero value",
+				"warning: Config.Timeout: zero value",
+				"warning: Config.Servers[0].Port: zero value",
+				"warning: Config.Labels[b].Host: zero value",
+			},
+		},
+		{
+			Name: "err_warn_zero_strict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -warn-zero -strict",
+			Files: map[string]string{
+				"input.yaml": "id: 1\nname: x\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Base    "yaml:\",inline\""
+						Name    string            "yaml:\"name\""
+						Timeout int               "yaml:\"timeout\""
+						Servers []Server          "yaml:\"servers\""
+						Labels  map[string]Server "yaml:\"labels\""
+						Ignored int               "yaml:\"-\""
+					}
+					type Base struct { ID int "yaml:\"id\"" }
+					type Server struct {
+						Host string "yaml:\"host\""
+						Port int    "yaml:\"port\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Timeout: zero value",
+				"Config.Servers: zero value",
+				"Config.Labels: zero value",
+			},
+		},
+		{
+			Name: "warn_zero",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -warn-zero -strict",
+			Files: map[string]string{
+				"input.yaml": "id: 1\nname: x\ntimeout: 5\nservers: [{host: a, port: 2}]\n" +
+					"labels: {b: {host: b, port: 1}}\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Base    "yaml:\",inline\""
+						Name    string            "yaml:\"name\""
+						Timeout int               "yaml:\"timeout\""
+						Servers []Server          "yaml:\"servers\""
+						Labels  map[string]Server "yaml:\"labels\""
+						Ignored int               "yaml:\"-\""
+					}
+					type Base struct { ID int "yaml:\"id\"" }
+					type Server struct {
+						Host string "yaml:\"host\""
+						Port int    "yaml:\"port\""
+					}
+				`,
+			},
+		},
+
+		// Config file
+		{
+			Name: "err_config_missing_files",
+			Args: "-config $SETUP/.valfile.yaml",
+			Files: map[string]string{
+				".valfile.yaml": `
+targets:
+  - package: tstcmd
+    type: Config
+    files: [input.json, prod.json, dev.json]
+`,
+				"input.json": `{"foo":"bar"}`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"missing input files: $SETUP/prod.json, $SETUP/dev.json",
+			},
+		},
+		{
+			Name: "err_config_extra_files",
+			Args: "-config $SETUP/.valfile.yaml -warn-extra-files",
+			Files: map[string]string{
+				".valfile.yaml": `
+targets:
+  - package: tstcmd
+    type: Config
+    files: [input.json]
+`,
+				"input.json":        `{"foo":"bar"}`,
+				"staging.json":      `{"foo":"baz"}`,
+				"deploy/prod.yaml":  "foo: x\n",
+				"deploy/README.md":  "ignored",
+				".git/config.yaml":  "ignored: true\n",
+				"vendor/mod/a.toml": "ignored = true\n",
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"warning: $SETUP/deploy/prod.yaml: not validated by any target",
+				"warning: $SETUP/staging.json: not validated by any target",
+			},
+		},
+		{
+			Name: "err_config_conflicting_params",
+			Args: "-config $SETUP/.valfile.yaml -t Config",
+			Files: map[string]string{
+				".valfile.yaml": `targets: []`,
+			},
+			ExpectErrs: []string{"conflicting parameters, " +
+				"-config is mutually exclusive with -t, -f and -env"},
+		},
+
+		// Unknown fields
+		{
+			Name: "err_json_unknown_field",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"bar":"baz"}`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"baz\"" }
+				`,
+			},
+			ExpectErrs: []string{`json: unknown field "bar"`},
+		},
+		{
+			Name: "err_yaml_slice_of_maps_unknown_field",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "steps:\n" +
+					"  - build: {image: golang}\n" +
+					"  - test: {image: golang, ports: 80}\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Steps []map[string]ServiceSpec "json:\"steps\" yaml:\"steps\" validate:\"dive,dive\""
+					}
+					type ServiceSpec struct {
+						Image string "json:\"image\" yaml:\"image\" valfile:\"required\""
+						Port  int    "json:\"port\" yaml:\"port\" validate:\"gte=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"yaml: unmarshal errors:\n" +
+				"  line 3: field ports not found in type main.ServiceSpec"},
+		},
+		{
+			Name: "err_yaml_slice_of_maps_inner_field",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "steps:\n" +
+					"  - build: {image: golang}\n" +
+					"  - test: {port: -1}\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Steps []map[string]ServiceSpec "json:\"steps\" yaml:\"steps\" validate:\"dive,dive\""
+					}
+					type ServiceSpec struct {
+						Image string "json:\"image\" yaml:\"image\" valfile:\"required\""
+						Port  int    "json:\"port\" yaml:\"port\" validate:\"gte=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Steps[1][test].Image: missing required field "image"`,
+				"Key: 'Config.Steps[1][test].Port' Error:Field validation " +
+					"for 'Port' failed on the 'gte' tag",
+			},
+		},
+		{
+			Name: "err_json_slice_of_maps_unknown_field",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"steps":[{"build":{"image":"golang","ports":80}}]}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Steps []map[string]ServiceSpec "json:\"steps\" yaml:\"steps\" validate:\"dive,dive\""
+					}
+					type ServiceSpec struct {
+						Image string "json:\"image\" yaml:\"image\" valfile:\"required\""
+						Port  int    "json:\"port\" yaml:\"port\" validate:\"gte=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`json: unknown field "ports"`},
+		},
+
+		// Aliases
+		{
+			Name: "err_alias_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"servers":[{"addr":"a"},{"address":"b"}],"n":1}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Servers []Server "json:\"servers\""
+						Num     int      "json:\"num\" valfile:\"aliases=n,number\""
+					}
+					type Server struct {
+						Addr string "json:\"addr\" valfile:\"aliases=address\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`warning: Config.Servers[1].Addr: "address" is a deprecated alias of "addr"`,
+				`warning: Config.Num: "n" is a deprecated alias of "num"`,
+			},
+		},
+		{
+			Name: "err_alias_yaml_both_set",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "name: a\nold_name: b\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "yaml:\"name\" valfile:\"aliases=old_name\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Name: "name" and its deprecated alias "old_name" are both set`,
+				"yaml: unmarshal errors:\n" +
+					"  line 2: field old_name not found in type main.Config",
+			},
+		},
+		{
+			Name:    "err_alias_env",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"DB_HOSTNAME=localhost"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { DB DB "env:\"DB_\"" }
+					type DB struct {
+						Host string "env:\"HOST\" valfile:\"aliases=HOSTNAME\" validate:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`warning: Config.DB.Host: "DB_HOSTNAME" is a deprecated alias of "DB_HOST"`,
+			},
+		},
+
+		// Required fields
+		{
+			Name: "err_fields_required_by_default_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-fields-required-by-default",
+			Files: map[string]string{
+				"input.json": `{"foo":"bar","sub":{}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Foo string "json:\"foo\""
+						Bar string "json:\"bar\""
+						Baz string "json:\"baz,omitempty\""
+						Opt string "json:\"opt\" valfile:\"optional\""
+						Sub Sub    "json:\"sub\""
+					}
+					type Sub struct { Name string "json:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Bar: missing required field "bar"`,
+				`Config.Sub.Name: missing required field "name"`,
+			},
+		},
+		{
+			Name: "err_fields_required_by_default_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml " +
+				"-fields-required-by-default",
+			Files: map[string]string{
+				"input.yaml": "items:\n  - name: a\n  - {}\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Items []Item "yaml:\"items\"" }
+					type Item struct { Name string "yaml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{`Config.Items[1].Name: missing required field "name"`},
+		},
+		{
+			Name:    "err_fields_required_by_default_env",
+			Args:    "-p $SETUP/tstcmd -t Config -env -fields-required-by-default",
+			EnvVars: []string{"FOO=bar"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Foo string "env:\"FOO\""
+						Bar string "env:\"BAR\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Bar: missing required field "BAR"`},
+		},
+		{
+			Name: "err_env_prefix_required",
+			Args: "-p $SETUP/tstcmd -t Config -env -fields-required-by-default",
+			EnvVars: []string{
+				"DB_HOST=localhost", "DB_REPLICA_HOST=replica", "PORT=80",
+			},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						DB   DBConfig "env:\"DB_\""
+						Port int      "env:\"PORT\""
+					}
+					type DBConfig struct {
+						Host    string  "env:\"HOST\""
+						Port    int     "env:\"PORT\""
+						Replica Replica "env:\"REPLICA_\""
+					}
+					type Replica struct {
+						Host string "env:\"HOST\""
+						Port int    "env:\"PORT\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.DB.Port: missing required field "DB_PORT"`,
+				`Config.DB.Replica.Port: missing required field "DB_REPLICA_PORT"`,
+			},
+		},
+		{
+			Name: "err_required_enum_omitted",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "name: x\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name  string   "yaml:\"name\""
+						Level LogLevel "yaml:\"level\" valfile:\"required\" validate:\"oneof=debug info\""
+					}
+					type LogLevel string
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Level: missing required field "level"`,
+				"Key: 'Config.Level' Error:Field validation for 'Level' " +
+					"failed on the 'oneof' tag",
+			},
+		},
+		{
+			Name: "err_required_enum_zero",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "level: ''\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Level LogLevel "yaml:\"level\" valfile:\"required\" validate:\"oneof=debug info\""
+					}
+					type LogLevel string
+				`,
+			},
+			ExpectErrs: []string{
+				"Key: 'Config.Level' Error:Field validation for 'Level' " +
+					"failed on the 'oneof' tag",
+			},
+		},
+		{
+			Name: "err_required_field_toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
+			Files: map[string]string{
+				"input.toml": `foo="bar"`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Foo string "toml:\"foo\""
+						Bar string "toml:\"bar\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Bar: missing required field "bar"`},
+		},
+
+		// Unique elements
+		{
+			Name: "err_unique",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "ports: [80, 8080, 443, 8080]\n" +
+					"servers:\n  - name: a\n  - name: b\n  - name: a\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Ports   []int    "yaml:\"ports\" valfile:\"unique\""
+						Servers []Server "yaml:\"servers\" valfile:\"unique=name\""
+					}
+					type Server struct { Name string "yaml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Ports: duplicate value 8080",
+				`Config.Servers: duplicate name "a"`,
+			},
+		},
+
+		// Map keys
+		{
+			Name: "err_map_keys",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"services":{"api":{},"Web_1":{}},` +
+					`"regions":{"eu":1,"us":2,"mars":3}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Services map[string]Service "json:\"services\" valfile:\"keyregex=^[a-z][a-z0-9-]*$\""
+						Regions  map[string]int     "json:\"regions\" valfile:\"keyoneof=eu,us\""
+					}
+					type Service struct{}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Services: key "Web_1" doesn't match pattern "^[a-z][a-z0-9-]*$"`,
+				`Config.Regions: key "mars" isn't one of: eu, us`,
+			},
+		},
+
+		// Custom messages
+		{
+			Name: "err_custom_message",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "servers:\n  - port: 0\n  - port: 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Servers []Server "yaml:\"servers\" validate:\"dive\""
+						Env     string   "yaml:\"env\" valfile:\"required,message=set env to prod, or dev\""
+					}
+					type Server struct {
+						Port int "yaml:\"port\" validate:\"min=1,max=65535\" valfile:\"message=Port must be between 1 and 65535\""
+						Host string "yaml:\"host\" validate:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Env: set env to prod, or dev",
+				"Config.Servers[0].Port: Port must be between 1 and 65535\n" +
+					"Key: 'Config.Servers[0].Host' Error:Field validation " +
+					"for 'Host' failed on the 'required' tag\n" +
+					"Key: 'Config.Servers[1].Host' Error:Field validation " +
+					"for 'Host' failed on the 'required' tag",
+			},
+		},
+
+		// Input size
+		{
+			Name: "err_max_input_size",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -max-input-size 8",
+			Files: map[string]string{
+				"input.json": `{"name":"too long"}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"reading input file: input size 19 bytes " +
+				"exceeds the maximum of 8 bytes, see -max-input-size"},
+		},
+
+		// Overrides
+		{
+			Name: "err_set",
+			Args: "-p $SETUP/tstcmd -t Config -format json " +
+				"-set server.port=80 -set server.host=localhost -set debug=yes",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "json:\"server\""
+						Debug  bool   "json:\"debug\""
+					}
+					type Server struct {
+						Host string "json:\"host\""
+						Port int    "json:\"port\" validate:\"gt=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"json: cannot unmarshal string into Go struct " +
+				"field Config.debug of type bool"},
+		},
+		{
+			Name: "err_set_hcl",
+			Args: "-p $SETUP/tstcmd -t Config -format hcl -set port=80",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct { Port int "hcl:\"port\"" }`,
+			},
+			ExpectErrs: []string{"-set doesn't support format hcl"},
+		},
+		{
+			Name:  "err_set_conflict",
+			Args:  "-p $SETUP/tstcmd -t Config -format yaml -set a=1 -set a.b=2",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{
+				"conflicting overrides: a is not an object",
+			},
+		},
+
+		// Checksums
+		{
+			Name: "err_expect_sha256_mismatch",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -expect-sha256 " +
+				"9A15D119375B5027BB82337D4D21130403BD1FDCB371929D9DF194882E830B29",
+			Files: map[string]string{
+				"input.yaml":     "port: 8080\n",
+				"tstcmd/main.go": `package main; type Config struct { Port int "yaml:\"port\"" }`,
+			},
+			ExpectErrs: []string{"input SHA-256 checksum mismatch: expected " +
+				"9a15d119375b5027bb82337d4d21130403bd1fdcb371929d9df194882e830b29, " +
+				"got 04eeaa6d3c2a66678af8514f5c8777a8889296f351c790bd3fa21ed2f9dd482e"},
+		},
+		{
+			Name: "expect_sha256_gzip",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml.gz -expect-sha256 " +
+				sha256Hex(makeGzip("port: 8080\n")),
+			Files: map[string]string{
+				"input.yaml.gz":  makeGzip("port: 8080\n"),
+				"tstcmd/main.go": `package main; type Config struct { Port int "yaml:\"port\"" }`,
+			},
+		},
+		{
+			Name:       "err_expect_sha256_invalid",
+			Args:       "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -expect-sha256 abc",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{`invalid value "abc" for flag -expect-sha256: invalid SHA-256 checksum "abc"`},
+		},
+		{
+			Name:       "err_expect_sha256_env",
+			Args:       "-p $SETUP/tstcmd -t Config -env -expect-sha256 " + strings.Repeat("0", 64),
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"-expect-sha256 requires -f or -archive"},
+		},
+
+		// SOPS
+		{
+			Name: "err_sops",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/secrets.yaml -sops",
+			Files: map[string]string{
+				"secrets.yaml": "db:\n" +
+					"  host: ENC[AES256_GCM,data:bG9jYWxob3N0,iv:aXY=,tag:dGFn,type:str]\n" +
+					"  port: ENC[AES256_GCM,data:NTQzMg==,iv:aXY=,tag:dGFn,type:int]\n" +
+					"  tokens:\n" +
+					"    - ENC[AES256_GCM,data:YQ==,iv:aXY=,tag:dGFn,type:str]\n" +
+					"  user: admin\n" +
+					"  pool: 0\n" +
+					"sops:\n" +
+					"  version: 3.8.1\n" +
+					"  lastmodified: \"2024-01-01T00:00:00Z\"\n",
+				"tstcmd/main.go": `package main
+					type Config struct { DB DB "yaml:\"db\"" }
+					type DB struct {
+						Host     string   "yaml:\"host\" validate:\"hostname\""
+						Port     int      "yaml:\"port\" validate:\"gt=0\""
+						Tokens   []string "yaml:\"tokens\" validate:\"dive,len=32\""
+						User     string   "yaml:\"user\""
+						Password string   "yaml:\"password\" valfile:\"required\""
+						Pool     int      "yaml:\"pool\" validate:\"gt=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.DB.Password: missing required field "password"`,
+				"Key: 'Config.DB.Pool' Error:Field validation for 'Pool' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "err_sops_toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/secrets.toml -sops",
+			Files: map[string]string{
+				"secrets.toml":   "",
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{"-sops is only supported for YAML and JSON input"},
+		},
+
+		// Merge patches
+		{
+			Name: "err_merge_patch",
+			Args: "-p $SETUP/tstcmd -t Config -base $SETUP/base.json " +
+				"-merge-patch $SETUP/patch.json",
+			Files: map[string]string{
+				"base.json":  `{"host":"localhost","port":80,"tls":{"cert":"a.pem"}}`,
+				"patch.json": `{"host":null,"tls":{"cert":"b.pem"}}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Host string "json:\"host\" valfile:\"required\""
+						Port int    "json:\"port\""
+						TLS  TLS    "json:\"tls\""
+					}
+					type TLS struct { Cert string "json:\"cert\"" }
+				`,
+			},
+			ExpectErrs: []string{`Config.Host: missing required field "host"`},
+		},
+		{
+			Name:       "err_merge_patch_missing_base",
+			Args:       "-p $SETUP/tstcmd -t Config -merge-patch $SETUP/patch.json",
+			Files:      map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"missing merge patch base, use -base"},
+		},
+		{
+			Name: "err_merge_patch_conflict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-base $SETUP/base.json -merge-patch $SETUP/patch.json",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"conflicting parameters, -merge-patch " +
+				"is mutually exclusive with -f, -archive, -env and -set"},
+		},
+
+		// Enums
+		{
+			Name: "err_enums",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -enums",
+			Files: map[string]string{
+				"input.json": `{
+					"level": 99,
+					"levels": [1, 2, 7],
+					"mode": 3,
+					"byName": {"a": 40},
+					"count": 99
+				}`,
+				"tstcmd/main.go": `package main
+					type Severity int
+					const (
+						SeverityInfo Severity = iota
+						SeverityWarning
+						SeverityError
+					)
+					type Mode uint8
+					const (
+						ModeRead  Mode = 1
+						ModeWrite Mode = 2
+						ModeAll        = ModeRead | ModeWrite
+					)
+					type Code int
+					const CodeOK Code = 20
+					const CodeNotFound = Code(40)
+					type Config struct {
+						Level  Severity        "json:\"level\""
+						Levels []Severity      "json:\"levels\""
+						Mode   Mode            "json:\"mode\""
+						ByName map[string]Code "json:\"byName\""
+						Count  int             "json:\"count\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Level: 99 is not a valid Severity",
+				"Config.Levels[2]: 7 is not a valid Severity",
+			},
+		},
+		{
+			Name: "enums_disabled",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "level: 99\n",
+				"tstcmd/main.go": `package main
+					type Severity int
+					const (
+						SeverityInfo Severity = iota
+						SeverityWarning
+					)
+					type Config struct {
+						Level Severity "yaml:\"level\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_enums_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -enums",
+			Files: map[string]string{
+				"input.yaml": "level: 2\nother: 5\n",
+				"tstcmd/main.go": `package main
+					type Severity int
+					const (
+						SeverityInfo Severity = iota
+						SeverityWarning
+					)
+					type Config struct {
+						Level Severity "yaml:\"level\""
+						Other Severity "yaml:\"other\""
+						Unset Severity "yaml:\"unset\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Level: 2 is not a valid Severity",
+				"Config.Other: 5 is not a valid Severity",
+			},
+		},
+
+		// Strict strings
+		{
+			Name: "err_strict_strings",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -strict-strings",
+			Files: map[string]string{
+				"input.yaml": `country: no
+version: 1.10
+name: "no"
+tags: [on, "off", x]
+labels: {zone: 1}
+region: ~
+port: 8080
+`,
+				"tstcmd/main.go": `package main
+					type Base struct { Region string "yaml:\"region\"" }
+					type Config struct {
+						Base   "yaml:\",inline\""
+						Country string            "yaml:\"country\""
+						Version string            "yaml:\"version\""
+						Name    string            "yaml:\"name\""
+						Tags    []string          "yaml:\"tags\""
+						Labels  map[string]string "yaml:\"labels\""
+						Port    int               "yaml:\"port\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`warning: Config.Country: unquoted value "no" is a boolean ` +
+					"in YAML 1.1, quote it to keep it a string",
+				`warning: Config.Version: unquoted value "1.10" is a float ` +
+					"in YAML, quote it to keep it a string",
+				`warning: Config.Tags[0]: unquoted value "on" is a boolean ` +
+					"in YAML 1.1, quote it to keep it a string",
+				`warning: Config.Labels[zone]: unquoted value "1" is an integer ` +
+					"in YAML, quote it to keep it a string",
+				`warning: Config.Region: unquoted value "~" is null ` +
+					"in YAML, quote it to keep it a string",
+			},
+		},
+		{
+			Name: "err_strict_strings_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -strict-strings",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"-strict-strings is only supported for YAML input"},
+		},
+		{
+			Name: "err_strict_strings_kinds",
+			Args: "-p $SETUP/tstcmd -kind DB=DBConfig -strict-strings " +
+				"-f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "kind: DB\ndsn: x\n---\nkind: DB\ndsn: off\n",
+				"tstcmd/main.go": `package main
+					type DBConfig struct {
+						Kind string "yaml:\"kind\""
+						DSN  string "yaml:\"dsn\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`warning: document 1: DBConfig.DSN: unquoted value "off" is ` +
+					"a boolean in YAML 1.1, quote it to keep it a string",
+			},
+		},
+
+		// Output templates
+		{
+			Name: "err_output_template_conflict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-output junit -output-template $SETUP/out.tmpl",
+			Files: map[string]string{"tstcmd/main.go": `package main`},
+			ExpectErrs: []string{"conflicting parameters, " +
+				"-output-template and -output are mutually exclusive"},
+		},
+
+		// Empty inputs
+		{
+			Name: "err_fail_on_empty_file",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -fail-on-empty",
+			Files: map[string]string{
+				"input.yaml": "\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "yaml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"Config: input is empty"},
+		},
+		{
+			Name: "err_fail_on_empty_zero",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -fail-on-empty",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"Config: input is empty"},
+		},
+		{
+			Name: "err_fail_on_empty_required",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.xml -fail-on-empty",
+			Files: map[string]string{
+				"input.xml": "",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "xml:\"name\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"Config: input is empty"},
+		},
+
+		// Empty inputs decode to the zero value in all formats
+		{
+			Name: "empty_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "# nothing here\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "yaml:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name: "empty_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": " \n",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name: "empty_toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
+			Files: map[string]string{
+				"input.toml": "",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "toml:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name: "empty_hcl",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": "",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "hcl:\"name,optional\"" }
+				`,
+			},
+		},
+		{
+			Name: "empty_jsonnet",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
+			Files: map[string]string{
+				"input.jsonnet": "\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "json:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_empty_json_required",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": "",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "json:\"name\" valfile:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Name: missing required field "name"`},
+		},
+		{
+			Name: "err_empty_yaml_validate",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "yaml:\"name\" validate:\"required\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"Key: 'Config.Name' Error:Field validation " +
+				"for 'Name' failed on the 'required' tag"},
+		},
+		{
+			Name: "err_fail_on_empty_toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml -fail-on-empty",
+			Files: map[string]string{
+				"input.toml": "",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "toml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"Config: input is empty"},
+		},
+		{
+			Name: "err_fail_on_empty_yaml_comments",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -fail-on-empty",
+			Files: map[string]string{
+				"input.yaml": "# nothing here\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Name string "yaml:\"name\"" }
+				`,
+			},
+			ExpectErrs: []string{"Config: input is empty"},
+		},
+
+		// Byte sizes
+		{
+			Name: "err_bytesize",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "max_size: 512XB\ncache_size: 2GiB\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						MaxSize   int64 "yaml:\"max_size\" valfile:\"bytesize\""
+						CacheSize int64 "yaml:\"cache_size\" valfile:\"bytesize\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.MaxSize: invalid byte size "512XB"`},
+		},
+		{
+			Name: "err_bytesize_hcl",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": "max_size = \"2GiB\"\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						MaxSize int64 "hcl:\"max_size\" valfile:\"bytesize\""
+						Server  struct {
+							Port int "hcl:\"port\" valfile:\"aliases=listen,lport\""
+						} "hcl:\"server,block\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.MaxSize: valfile option "bytesize" isn't supported for hcl input`,
+				`Config.Server.Port: valfile option "aliases" isn't supported for hcl input`,
+			},
+		},
+		{
+			Name: "bytesize_json_large_integers",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"max_size": "2GiB", "id": 18446744073709551615}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						MaxSize int64  "json:\"max_size\" valfile:\"bytesize\""
+						ID      uint64 "json:\"id\" validate:\"eq=18446744073709551615\""
+					}
+				`,
+			},
+		},
+		{
+			Name:    "err_bytesize_env",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"MAX_SIZE=2GiB"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						MaxSize int64 "env:\"MAX_SIZE\" valfile:\"bytesize\" validate:\"lte=1073741824\""
+					}
+				`,
+			},
+			ExpectErrs: []string{"Key: 'Config.MaxSize' Error:Field validation " +
+				"for 'MaxSize' failed on the 'lte' tag"},
+		},
+
+		// Duration bounds
+		{
+			Name: "err_duration_bounds",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "timeout: 2h\ninterval: 500ms\nretry: 1m30s\n",
+				"tstcmd/main.go": `package main
+					import "time"
+					type Config struct {
+						Timeout  time.Duration  "yaml:\"timeout\" valfile:\"min=1s,max=1h\""
+						Interval *time.Duration "yaml:\"interval\" valfile:\"min=1s\""
+						Retry    time.Duration  "yaml:\"retry\" valfile:\"max=5m\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Timeout: 2h exceeds max 1h",
+				"Config.Interval: 500ms is below min 1s",
+			},
+		},
+		{
+			Name: "err_duration_bounds_non_duration",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "port: 80\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\" valfile:\"max=1h\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Port: valfile min and max are only supported for time.Duration",
+			},
+		},
+
+		// Paths
+		{
+			Name: "err_paths",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/conf/input.json",
+			Files: map[string]string{
+				"conf/input.json": `{"cert":"cert.pem","key":"missing.pem",` +
+					`"data":"cert.pem","tmp":"/nonexistent/tmp","ok":"certs"}`,
+				"conf/cert.pem":    "x",
+				"conf/certs/a.pem": "x",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Cert string "json:\"cert\" valfile:\"file\""
+						Key  string "json:\"key\" valfile:\"file\""
+						Data string "json:\"data\" valfile:\"dir\""
+						Tmp  string "json:\"tmp\" valfile:\"dir\""
+						OK   string "json:\"ok\" valfile:\"dir\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Key: file not found: $SETUP/conf/missing.pem",
+				"Config.Data: not a directory: $SETUP/conf/cert.pem",
+				"Config.Tmp: directory not found: /nonexistent/tmp",
+			},
+		},
+		{
+			Name: "err_paths_base_dir",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -base-dir $SETUP/etc",
+			Files: map[string]string{
+				"input.yaml": "cert: cert.pem\n",
+				"cert.pem":   "x",
+				"tstcmd/main.go": `package main
+					type Config struct { Cert string "yaml:\"cert\" valfile:\"file\"" }
+				`,
+			},
+			ExpectErrs: []string{"Config.Cert: file not found: $SETUP/etc/cert.pem"},
+		},
+
+		// Standard library types
+		{
+			Name: "err_big_int_malformed",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"supply":"12x"}`,
+				"tstcmd/main.go": `package main
+					import "math/big"
+					type Config struct { Supply *big.Int "json:\"supply\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`math/big: cannot unmarshal "\"12x\"" into a *big.Int`,
+			},
+		},
+		{
+			Name: "err_big_int_malformed_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "supply: 1.5\n",
+				"tstcmd/main.go": `package main
+					import "math/big"
+					type Config struct { Supply big.Int "yaml:\"supply\"" }
+				`,
+			},
+			ExpectErrs: []string{`math/big: cannot unmarshal "1.5" into a *big.Int`},
+		},
+		{
+			Name: "err_selector_non_std",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					import "example.com/money"
+					type Config struct { Price money.Amount "json:\"price\"" }
+				`,
+			},
+			ExpectErrs: []string{`undefined type: money.Amount: package ` +
+				`"example.com/money" can't be located, the package isn't part of a module`},
+		},
+		{
+			Name: "imported_types",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{
+					"level": 2,
+					"log": {"level": "debug", "output": "stderr"},
+					"price": {"cents": 100},
+					"Extra": "x"
+				}`,
+				"go.mod": "module example.com/app\n\ngo 1.21\n",
+				"cmd/main.go": `package main
+					import (
+						"example.com/app/logging"
+						"example.com/money"
+					)
+					type Level int
+					type Config struct {
+						Level Level           "json:\"level\""
+						Log   logging.Options "json:\"log\""
+						Price *money.Amount   "json:\"price\""
+						logging.Extra
+					}
+				`,
+				"logging/logging.go": `package logging
+					import "time"
+					type Options struct {
+						Level   Level         "json:\"level\""
+						Output  string        "json:\"output\""
+						Timeout time.Duration "json:\"timeout\""
+					}
+					type Level string
+					type Extra struct { Extra string "json:\"Extra\"" }
+				`,
+				"logging/logging_test.go": `package logging_test`,
+				"vendor/example.com/money/money.go": `package money
+					type Amount struct { Cents int64 "json:\"cents\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_imported_types",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import log "example.com/app/internal/logging"
+					type Config struct {
+						Log   log.Options "json:\"log\""
+						Extra Extra       "json:\"extra\""
+					}
+				`,
+				"internal/logging/logging.go": `package logging
+					type Options struct {
+						Level  Level  "json:\"level\""
+						Output string
+						Sink   sink   "json:\"sink\""
+					}
+					type sink struct {
+						Addr     Addr    "json:\"addr\""
+						Fallback []*Addr "json:\"fallback\""
+					}
+				`,
+			},
+			// Every undefined type is reported once.
+			ExpectErrs: []string{
+				"undefined type: logging.Level",
+				"undefined type: logging.Addr",
+				"undefined type: Extra",
+			},
+		},
+		{
+			Name: "err_imported_types_tags",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import "example.com/app/logging"
+					type Config struct { Log logging.Options "json:\"log\"" }
+				`,
+				"logging/logging.go": `package logging
+					type Options struct {
+						Level  string "json:\"level\""
+						Output string
+					}
+				`,
+			},
+			ExpectErrs: []string{`Options.Output: missing tag "json"`},
+		},
+		{
+			Name: "err_imported_types_cycle",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import "example.com/app/a"
+					type Config struct { A a.A "json:\"a\"" }
+				`,
+				"a/a.go": `package a
+					import "example.com/app/b"
+					type A struct { B *b.B "json:\"b\"" }
+				`,
+				"b/b.go": `package b
+					import "example.com/app/a"
+					type B struct { A *a.A "json:\"a\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"undefined type: a.A: import cycle not allowed: " +
+					"example.com/app/b -> example.com/app/a -> example.com/app/b",
+			},
+		},
+		{
+			Name: "err_imported_types_not_found",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import "example.com/money"
+					type Config struct { Price money.Amount "json:\"price\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`undefined type: money.Amount: package "example.com/money" ` +
+					"is neither part of module example.com/app nor vendored",
+			},
+		},
+		{
+			Name: "generic_types",
+			Args: "-p $SETUP/tstcmd -t Config[Prod] -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{
+					"env": {"region": "eu"},
+					"items": [{"name": "a"}],
+					"pair": {"key": "k", "value": 1},
+					"next": {"env": {"region": "us"}}
+				}`,
+				"tstcmd/main.go": `package main
+					type Config[T any] struct {
+						Env   T                 "json:\"env\""
+						Items List[Item]        "json:\"items\""
+						Pair  Pair[string, int] "json:\"pair\""
+						Next  *Config[T]        "json:\"next\""
+					}
+					type Prod struct { Region string "json:\"region\"" }
+					type Item struct { Name string "json:\"name\"" }
+					type List[E any] []E
+					type Pair[K comparable, V any] struct {
+						Key   K "json:\"key\""
+						Value V "json:\"value\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_generic_types",
+			Args: "-p $SETUP/tstcmd -t Config[Prod] -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"env": {"region": "eu", "zone": 1}, "dev": {}}`,
+				"tstcmd/main.go": `package main
+					type Config[T any] struct {
+						Env T          "json:\"env\""
+						Dev Config[Dev] "json:\"dev\""
+					}
+					type Prod struct { Region string "json:\"region\"" }
+					type Dev struct { Debug bool }
+				`,
+			},
+			ExpectErrs: []string{`Dev.Debug: missing tag "json"`},
+		},
+		{
+			Name: "err_generic_types_input",
+			Args: "-p $SETUP/tstcmd -t Config[Prod] -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"env": {"region": "eu", "zone": 1}}`,
+				"tstcmd/main.go": `package main
+					type Config[T any] struct { Env T "json:\"env\"" }
+					type Prod struct { Region string "json:\"region\"" }
+				`,
+			},
+			ExpectErrs: []string{`json: unknown field "zone"`},
+		},
+		{
+			Name: "err_generic_types_without_type_args",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config[T any] struct { Env T "json:\"env\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"type Config is generic, specify its type arguments, e.g. -t 'Config[T]'",
+			},
+		},
+		{
+			Name: "err_generic_types_type_args_count",
+			Args: "-p $SETUP/tstcmd -t Config[int,string] -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config[T any] struct { Env T "json:\"env\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"wrong number of type arguments for Config: expected 1, got 2",
+			},
+		},
+		{
+			Name: "err_generic_types_not_generic",
+			Args: "-p $SETUP/tstcmd -t Config[int] -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Env int "json:\"env\"" }
+				`,
+			},
+			ExpectErrs: []string{"type Config is not generic"},
+		},
+		{
+			Name: "alias_types",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"name": "x", "port": 80, "timeout": 1}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import "example.com/app/internal/config"
+					type Config = Base
+					type Base = config.Config
+				`,
+				"internal/config/config.go": `package config
+					import "time"
+					type Config struct {
+						Name    string  "json:\"name\""
+						Port    Port    "json:\"port\""
+						Timeout Timeout "json:\"timeout\""
+					}
+					type Port = int
+					type Timeout = time.Duration
+				`,
+			},
+		},
+		{
+			Name: "err_alias_types",
+			Args: "-p $SETUP/cmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"name": "x", "port": 80}`,
+				"go.mod":     "module example.com/app\n",
+				"cmd/main.go": `package main
+					import "example.com/app/internal/config"
+					type Config = config.Config
+				`,
+				"internal/config/config.go": `package config
+					type Config struct {
+						Name string "json:\"name\""
+						Port int
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Port: missing tag "json"`},
+		},
+		{
+			Name: "err_alias_types_cycle",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					type Config = A
+					type A = B
+					type B = A
+				`,
+			},
+			ExpectErrs: []string{"invalid recursive type alias: A -> B -> A"},
+		},
+
+		// Non-empty environment variables
+		{
+			Name:    "err_env_nonempty",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"PORT=", "HOST=", "USER=admin"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port  int    "env:\"PORT\" valfile:\"nonempty\""
+						Host  string "env:\"HOST\""
+						User  string "env:\"USER\" valfile:\"nonempty\""
+						Token string "env:\"TOKEN\" valfile:\"nonempty\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"Config.Port: env var PORT is empty",
+				`Config.Token: missing required field "TOKEN"`,
+			},
+		},
+		{
+			Name: "nonempty_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"host":""}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Host  string "json:\"host\" valfile:\"nonempty\""
+						Token string "json:\"token\" valfile:\"nonempty\""
+					}
+				`,
+			},
+		},
+
+		// Exact environment
+		{
+			Name: "err_env_exact",
+			Args: "-p $SETUP/tstcmd -t Config -env -env-exact -env-prefix APP_",
+			EnvVars: []string{
+				"APP_DB_HOST=localhost", "APP_DB_HOSTNAME=x", "APP_PORT=80", "HOME=/root",
+			},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						DB      DB     "env:\"DB_\""
+						Port    int    "env:\"PORT\""
+						Debug   bool   "env:\"DEBUG\""
+						Release string "env:\"RELEASE\" valfile:\"required\""
+					}
+					type DB struct { Host string "env:\"HOST\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Debug: environment variable "APP_DEBUG" is not set`,
+				`Config.Release: missing required field "RELEASE"`,
+				`unknown environment variable "APP_DB_HOSTNAME"`,
+			},
+		},
+		{
+			Name:    "err_env_exact_prefix",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-exact",
+			EnvVars: []string{"PORT=80"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "env:\"PORT\"" }
+				`,
+			},
+			ExpectErrs: []string{"-env-exact requires -env-prefix with -env"},
+		},
+		{
+			Name:    "env_prefix",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-exact -env-prefix MYAPP_",
+			EnvVars: []string{"MYAPP_PORT=80", "MYAPP_DB_HOST=localhost", "HOME=/root"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						DB   DB  "env:\"DB_\""
+						Port int "env:\"PORT\""
+					}
+					type DB struct { Host string "env:\"HOST\"" }
+				`,
+			},
+		},
+		{
+			Name:    "err_env_prefix",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-exact -env-prefix MYAPP_",
+			EnvVars: []string{"MYAPP_PORT=80", "MYAPP_HOST=localhost", "PORT=x"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "env:\"PORT\"" }
+				`,
+			},
+			ExpectErrs: []string{`unknown environment variable "MYAPP_HOST"`},
+		},
+		{
+			Name: "err_env_prefix_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -env-prefix MYAPP_",
+			Files: map[string]string{
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{"-env-prefix requires -env"},
+		},
+		{
+			Name:    "env_strict",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-strict -env-prefix MYAPP_",
+			EnvVars: []string{"MYAPP_PORT=80", "HOME=/root"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "env:\"PORT\""
+						Host string "env:\"HOST\""
+					}
+				`,
+			},
+		},
+		{
+			Name:    "err_env_strict",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-strict -env-prefix MYAPP_",
+			EnvVars: []string{"MYAPP_PROT=80", "MYAPP_DB_HOST=x", "HOME=/root"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int "env:\"PORT\""
+						DB   DB  "env:\"DB_\""
+					}
+					type DB struct { Host string "env:\"HOST\"" }
+				`,
+			},
+			ExpectErrs: []string{`unknown environment variable "MYAPP_PROT"`},
+		},
+		{
+			Name: "err_env_strict_dotenv",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/.env -env-strict",
+			Files: map[string]string{
+				".env": "PORT=80\nDEBUG=true\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "env:\"PORT\"" }
+				`,
+			},
+			ExpectErrs: []string{`unknown environment variable "DEBUG"`},
+		},
+		{
+			Name:    "err_env_strict_prefix",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-strict",
+			EnvVars: []string{"PORT=80"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "env:\"PORT\"" }
+				`,
+			},
+			ExpectErrs: []string{"-env-strict requires -env-prefix with -env"},
+		},
+		{
+			Name: "env_case_insensitive",
+			Args: "-p $SETUP/tstcmd -t Config -env -env-exact -env-prefix APP_ " +
+				"-env-case-insensitive",
+			EnvVars: []string{"APP_DB_HOST=localhost", "APP_PORT=80"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						DB   DB  "env:\"db_\""
+						Port int "env:\"port\" valfile:\"required\""
+					}
+					type DB struct { Host string "env:\"host\"" }
+				`,
+			},
+		},
+		{
+			Name:    "err_env_case_insensitive",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-case-insensitive",
+			EnvVars: []string{"PORT=80", "Port=81", "HOST=x"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "env:\"port\""
+						Host string "env:\"host\" validate:\"hostname\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Port: "PORT" and "Port" both match "port"`,
+			},
+		},
+		{
+			Name:    "err_env_case_sensitive",
+			Args:    "-p $SETUP/tstcmd -t Config -env -env-exact -env-prefix APP_",
+			EnvVars: []string{"APP_PORT=80"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "env:\"port\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Port: environment variable "APP_port" is not set`,
+				`unknown environment variable "APP_PORT"`,
+			},
+		},
+		{
+			Name: "err_env_case_insensitive_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-env-case-insensitive",
+			Files: map[string]string{
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				"-env-case-insensitive is only supported for environment variables",
+			},
+		},
+		{
+			Name: "err_env_exact_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -env-exact",
+			Files: map[string]string{
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{"-env-exact is only supported for environment variables"},
+		},
+
+		// .envrc
+		{
+			Name: "err_envrc",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/.envrc -fields-required-by-default",
+			Files: map[string]string{
+				".envrc": "use nix\nexport HOST=localhost\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Host string "env:\"HOST\""
+						Port int    "env:\"PORT\""
+					}
+				`,
+			},
+			ExpectErrs: []string{`Config.Port: missing required field "PORT"`},
+		},
+		{
+			Name: "err_envrc_strict",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/.envrc -envrc-strict",
+			Files: map[string]string{
+				".envrc": "use nix\nexport HOST=localhost\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Host string "env:\"HOST\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`parsing .envrc file: line 1: unsupported statement: "use nix"`,
+			},
+		},
+
+		// Success
+		{
+			Name: "fields_required_by_default_toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml " +
+				"-fields-required-by-default",
+			Files: map[string]string{
+				"input.toml": "foo=false\n[sub]\nname=\"x\"\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Foo bool "toml:\"foo\""
+						Sub Sub  "toml:\"sub\""
+					}
+					type Sub struct { Name string "toml:\"name\"" }
+				`,
+			},
+		},
+		{
+			Name:    "env_vars",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"FOO=bar"},
+			Files: map[string]string{
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "env:\"foo\"" }
+				`,
+			},
+		},
+		{
+			Name: "required_enum",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "level: info\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Level LogLevel "yaml:\"level\" valfile:\"required\" validate:\"oneof=debug info\""
+					}
+					type LogLevel string
+				`,
+			},
+		},
+		{
+			Name:    "env_prefix",
+			Args:    "-p $SETUP/tstcmd -t Config -env",
+			EnvVars: []string{"DB_HOST=localhost", "DB_PORT=5432"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						DB struct {
+							Host string "env:\"HOST\""
+							Port int    "env:\"PORT\" validate:\"eq=5432\""
+						} "env:\"DB_\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"foo":"bar"}`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
+			},
+		},
+		{
+			Name: "toml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
+			Files: map[string]string{
+				"input.toml": `foo="bar"`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "toml:\"foo\"" }
 				`,
 			},
-			ExpectErrs: []string{"missing type name"},
 		},
 		{
-			Name:    "err_missing_tag_env",
-			Args:    "-p $SETUP/tstcmd -t Config -env",
-			EnvVars: []string{"FOO=bar"},
+			Name: "jsonnet",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
 			Files: map[string]string{
-				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+				"input.jsonnet": `{foo:"bar"}`,
+				"tstcmd/main.go": `
+					package main; type Config struct { Foo string "json:\"foo\"" }
+				`,
 			},
-			ExpectErrs: []string{`Config.Foo: missing tag "env"`},
 		},
 		{
-			Name:    "err_missing_tag_json",
-			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
-			EnvVars: []string{"FOO=bar"},
+			Name: "jsonnet_ext_vars",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet " +
+				"-ext-str env=prod -ext-code replicas=1+2 -jpath $SETUP/lib",
 			Files: map[string]string{
-				"input.json":     `{"foo":"bar"}`,
-				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+				"input.jsonnet": `
+					local defaults = import "defaults.libsonnet";
+					defaults + {
+						env: std.extVar("env"),
+						replicas: std.extVar("replicas"),
+					}
+				`,
+				"lib/defaults.libsonnet": `{ port: 8080 }`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Env      string "json:\"env\" validate:\"oneof=dev prod\""
+						Replicas int    "json:\"replicas\" validate:\"eq=3\""
+						Port     int    "json:\"port\""
+					}
+				`,
 			},
-			ExpectErrs: []string{`Config.Foo: missing tag "json"`},
 		},
 		{
-			Name:    "err_missing_tag_toml",
-			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
-			EnvVars: []string{`foo="bar"`},
+			Name: "err_jsonnet_ext_vars",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
 			Files: map[string]string{
-				"input.toml":     `{"foo":"bar"}`,
-				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+				"input.jsonnet": `{ env: std.extVar("env") }`,
+				"tstcmd/main.go": `package main
+					type Config struct { Env string "json:\"env\"" }
+				`,
 			},
-			ExpectErrs: []string{`Config.Foo: missing tag "toml"`},
+			ExpectErrs: []string{"evaluating Jsonnet: RUNTIME ERROR: " +
+				"Undefined external variable: env\n" +
+				"\t$SETUP/input.jsonnet:1:8-25\tobject <anonymous>\n" +
+				"\tField \"env\"\t\n\tDuring manifestation\t\n"},
 		},
 		{
-			Name:    "err_missing_tag_jsonnet",
-			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
-			EnvVars: []string{`{foo:"bar"}`},
+			Name: "err_jsonnet_ext_vars_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -ext-str env=prod",
 			Files: map[string]string{
-				"input.jsonnet":  `{"foo":"bar"}`,
-				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				"-ext-str, -ext-code and -jpath are only supported for Jsonnet input",
 			},
-			ExpectErrs: []string{`Config.Foo: missing tag "json"`},
 		},
 		{
-			Name:    "err_missing_tag_hcl",
-			Args:    "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
-			EnvVars: []string{`{foo:"bar"}`},
+			Name: "err_jsonnet_ext_code_invalid",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet -ext-code env",
 			Files: map[string]string{
-				"input.hcl":      `foo="bar"`,
-				"tstcmd/main.go": `package main; type Config struct { Foo string }`,
+				"input.jsonnet":  `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				`invalid value "env" for flag -ext-code: ` +
+					`invalid external variable "env", expected key=expr`,
 			},
-			ExpectErrs: []string{`Config.Foo: missing tag "hcl"`},
 		},
-
-		// Unknown fields
 		{
-			Name: "err_json_unknown_field",
+			Name: "dot_import_std",
 			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
 			Files: map[string]string{
-				"input.json": `{"bar":"baz"}`,
-				"tstcmd/main.go": `
-					package main; type Config struct { Foo string "json:\"baz\"" }
+				"input.json": `{"timeout":1000}`,
+				"tstcmd/main.go": `package main
+					import . "time"
+					type Config struct { Timeout Duration "json:\"timeout\"" }
 				`,
 			},
-			ExpectErrs: []string{`json: unknown field "bar"`},
 		},
-
-		// Success
 		{
-			Name:    "env_vars",
-			Args:    "-p $SETUP/tstcmd -t Config -env",
-			EnvVars: []string{"FOO=bar"},
+			Name: "multi_doc",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -multi-doc",
 			Files: map[string]string{
-				"tstcmd/main.go": `
-					package main; type Config struct { Foo string "env:\"foo\"" }
+				"input.yaml": "name: a\nport: 80\n---\nname: b\nport: 81\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "yaml:\"name\""
+						Port int    "yaml:\"port\" validate:\"gt=0\""
+					}
 				`,
 			},
 		},
 		{
-			Name: "json",
-			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Name: "err_multi_doc",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -multi-doc",
+			Files: map[string]string{
+				"input.yaml": "name: a\nport: 80\n---\nname: b\nhost: x\n" +
+					"---\nname: c\nport: 0\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Name string "yaml:\"name\""
+						Port int    "yaml:\"port\" validate:\"gt=0\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				"document 1: yaml: unmarshal errors:\n" +
+					"  line 2: field host not found in type main.Config",
+				"document 2: Key: 'Config.Port' Error:Field validation " +
+					"for 'Port' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "err_multi_doc_first_only",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "port: 0\n---\nport: 0\n",
+				"tstcmd/main.go": `package main
+					type Config struct { Port int "yaml:\"port\" validate:\"gt=0\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "err_multi_doc_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -multi-doc",
+			Files: map[string]string{
+				"input.json":     `{}`,
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{"-multi-doc is only supported for YAML input"},
+		},
+		{
+			Name: "err_multi_doc_kind",
+			Args: "-p $SETUP/tstcmd -kind Server=Config -f $SETUP/input.yaml -multi-doc",
+			Files: map[string]string{
+				"input.yaml":     "kind: Server\n",
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				"conflicting parameters, -multi-doc and -kind are mutually exclusive",
+			},
+		},
+		{
+			Name: "err_kinds_undefined_types",
+			Args: "-p $SETUP/tstcmd -kind Server=ServerConfig -kind DB=DBConfig " +
+				"-f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "kind: Server\n",
+				"tstcmd/main.go": `package main
+					type ServerConfig struct { TLS TLS "yaml:\"tls\"" }
+					type DBConfig struct { Pool Pool "yaml:\"pool\"" }
+				`,
+			},
+			ExpectErrs: []string{"undefined type: Pool", "undefined type: TLS"},
+		},
+		{
+			Name: "err_emit_schema_kind",
+			Args: "-p $SETUP/tstcmd -kind Server=Config -emit-schema",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				"-emit-schema requires a single type, -kind and -any aren't supported",
+			},
+		},
+		{
+			Name: "err_emit_schema_explain_type",
+			Args: "-p $SETUP/tstcmd -t Config -emit-schema -explain-type",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct {}`,
+			},
+			ExpectErrs: []string{
+				"conflicting parameters, -emit-schema and -explain-type are mutually exclusive",
+			},
+		},
+		{
+			Name: "kinds",
+			Args: "-p $SETUP/tstcmd -kind Server=ServerConfig -kind DB=DBConfig " +
+				"-f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "kind: Server\nport: 80\n---\nkind: DB\ndsn: x\n",
+				"tstcmd/main.go": `package main
+					type ServerConfig struct {
+						Kind string "yaml:\"kind\""
+						Port int    "yaml:\"port\""
+					}
+					type DBConfig struct {
+						Kind string "yaml:\"kind\""
+						DSN  string "yaml:\"dsn\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "tag_fallback",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet " +
+				"-tag-fallback toml,yaml",
+			Files: map[string]string{
+				"input.jsonnet": `{foo:"bar", baz:"fuzz", b:"c"}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Foo string "yaml:\"foo\""
+						Bar string "yaml:\"baz\" toml:\"b\""
+						Baz string "json:\"baz\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "config",
+			Args: "-config $SETUP/.valfile.yaml",
 			Files: map[string]string{
+				".valfile.yaml": `
+targets:
+  - package: tstcmd
+    type: Config
+    files: [input.json]
+`,
 				"input.json": `{"foo":"bar"}`,
 				"tstcmd/main.go": `
 					package main; type Config struct { Foo string "json:\"foo\"" }
@@ -109,22 +3372,245 @@ func TestCLI(t *testing.T) {
 			},
 		},
 		{
-			Name: "toml",
-			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.toml",
+			Name: "archive",
+			Args: "-p $SETUP/tstcmd -t Config -archive $SETUP/bundle.zip -entry input.json",
 			Files: map[string]string{
-				"input.toml": `foo="bar"`,
+				"bundle.zip": makeZip(map[string]string{"input.json": `{"foo":"bar"}`}),
 				"tstcmd/main.go": `
-					package main; type Config struct { Foo string "toml:\"foo\"" }
+					package main; type Config struct { Foo string "json:\"foo\"" }
 				`,
 			},
 		},
 		{
-			Name: "jsonnet",
-			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.jsonnet",
+			Name: "big_int",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
 			Files: map[string]string{
-				"input.jsonnet": `{foo:"bar"}`,
-				"tstcmd/main.go": `
-					package main; type Config struct { Foo string "json:\"foo\"" }
+				"input.json": `{"supply":123456789012345678901234567890,"rate":"0.1"}`,
+				"tstcmd/main.go": `package main
+					import "math/big"
+					type Config struct {
+						Supply *big.Int   "json:\"supply\" validate:\"required\""
+						Rate   *big.Float "json:\"rate\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "big_int_yaml",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "supply: 123456789012345678901234567890\n",
+				"tstcmd/main.go": `package main
+					import "math/big"
+					type Config struct { Supply big.Int "yaml:\"supply\"" }
+				`,
+			},
+		},
+		{
+			Name: "std_selector",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"timeout":1000,"mode":420}`,
+				"tstcmd/main.go": `package main
+					import (
+						"os"
+						t "time"
+					)
+					type Config struct {
+						Timeout t.Duration  "json:\"timeout\""
+						Mode    os.FileMode "json:\"mode\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "err_std_selector_tags",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{}`,
+				"tstcmd/main.go": `package main
+					import (
+						"net"
+						"net/url"
+						"time"
+					)
+					type Config struct {
+						CreatedAt time.Time
+						Addr      net.IP
+						Endpoint  *url.URL "json:\"endpoint\""
+						Meta      Meta     "json:\"meta\""
+					}
+					type Meta struct {
+						UpdatedAt time.Time
+						Timeout   time.Duration "json:\"timeout\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.CreatedAt: missing tag "json"`,
+				`Config.Addr: missing tag "json"`,
+				`Meta.UpdatedAt: missing tag "json"`,
+			},
+		},
+		{
+			Name: "embedded_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json " +
+				"-fields-required-by-default",
+			Files: map[string]string{
+				"input.json": `{"name":"x","region":"eu","extra":"y"}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Common
+						*Location
+						Extra string "json:\"extra\""
+					}
+					type Common struct { Name string "json:\"name\" validate:\"required\"" }
+					type Location struct { Region string "json:\"region\"" }
+				`,
+			},
+		},
+		{
+			Name: "embedded_yaml_inline",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "name: x\nextra: y\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Common "yaml:\",inline\""
+						Extra  string "yaml:\"extra\""
+					}
+					type Common struct { Name string "yaml:\"name\" validate:\"required\"" }
+				`,
+			},
+		},
+		{
+			Name: "bytesize",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json",
+			Files: map[string]string{
+				"input.json": `{"limits":[{"max":"512MiB"},{"max":"1.5kB"},{"max":1024}]}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Limits []Limit "json:\"limits\" validate:\"dive\"" }
+					type Limit struct {
+						Max int64 "json:\"max\" valfile:\"bytesize\" validate:\"oneof=536870912 1500 1024\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "set",
+			Args: "-p $SETUP/tstcmd -t Config -format yaml " +
+				"-set server.port=8080 -set server.host=localhost -set debug=true",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "yaml:\"server\""
+						Debug  bool   "yaml:\"debug\""
+					}
+					type Server struct {
+						Host string "yaml:\"host\" validate:\"hostname\""
+						Port int    "yaml:\"port\" validate:\"eq=8080\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "set_toml",
+			Args: "-p $SETUP/tstcmd -t Config -format toml " +
+				"-set server.port=8080 -set server.host=localhost -set debug=true",
+			Files: map[string]string{
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "toml:\"server\""
+						Debug  bool   "toml:\"debug\" validate:\"eq=true\""
+					}
+					type Server struct {
+						Host string "toml:\"host\" validate:\"hostname\""
+						Port int    "toml:\"port\" validate:\"eq=8080\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "recursive_type",
+			Args: "-p $SETUP/tstcmd -t Tree -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "name: root\nchildren:\n" +
+					"  - name: a\n    children:\n      - name: a1\n" +
+					"  - name: b\n    meta:\n      owner:\n        name: o\n",
+				"tstcmd/main.go": `package main
+					type Tree struct {
+						Name     string "yaml:\"name\" validate:\"required\""
+						Children []Tree "yaml:\"children\" validate:\"dive\""
+						Meta     *Meta  "yaml:\"meta\""
+					}
+					type Meta struct { Owner *Tree "yaml:\"owner\"" }
+				`,
+			},
+		},
+		{
+			Name:    "print_effective_env",
+			Args:    "-p $SETUP/tstcmd -t Config -env -print-effective",
+			EnvVars: []string{"PORT=80"},
+			Files: map[string]string{
+				"tstcmd/main.go": `package main; type Config struct { Port int "env:\"PORT\"" }`,
+			},
+		},
+		{
+			Name: "expect_sha256",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.yaml -expect-sha256 " +
+				"9a15d119375b5027bb82337d4d21130403bd1fdcb371929d9df194882e830b29",
+			Files: map[string]string{
+				"input.yaml":     "port: 80\n",
+				"tstcmd/main.go": `package main; type Config struct { Port int "yaml:\"port\"" }`,
+			},
+		},
+		{
+			Name: "sops_json",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/secrets.json -sops",
+			Files: map[string]string{
+				"secrets.json": `{"token":"ENC[AES256_GCM,data:YQ==,iv:aXY=,tag:dGFn,type:str]",` +
+					`"sops":{"version":"3.8.1"}}`,
+				"tstcmd/main.go": `package main
+					type Config struct { Token string "json:\"token\" validate:\"len=32\"" }
+				`,
+			},
+		},
+		{
+			Name: "merge_patch",
+			Args: "-p $SETUP/tstcmd -t Config -base $SETUP/base.json " +
+				"-merge-patch $SETUP/patch.json",
+			Files: map[string]string{
+				"base.json":  `{"host":"localhost","legacy":true}`,
+				"patch.json": `{"legacy":null,"port":8080}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Host string "json:\"host\""
+						Port int    "json:\"port\" validate:\"gt=0\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "report_defaults",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.json -report-defaults json",
+			Files: map[string]string{
+				"input.json": `{"port":80}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Port int    "json:\"port\""
+						Host string "json:\"host\""
+					}
+				`,
+			},
+		},
+		{
+			Name: "any",
+			Args: "-p $SETUP/tstcmd -t ConfigV1 -t ConfigV2 -any -f $SETUP/input.yaml",
+			Files: map[string]string{
+				"input.yaml": "listen: 80\n",
+				"tstcmd/main.go": `package main
+					type ConfigV1 struct { Addr string "yaml:\"addr\"" }
+					type ConfigV2 struct { Listen int "yaml:\"listen\"" }
 				`,
 			},
 		},
@@ -138,6 +3624,126 @@ func TestCLI(t *testing.T) {
 				`,
 			},
 		},
+		{
+			Name: "hcl_blocks",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": `
+					service "api" {
+						name = "API"
+						port = 8080
+						tls {
+							cert = "api.pem"
+						}
+					}
+					service "web" {
+						port = 80
+					}
+					db "postgres" "primary" {
+						host = "localhost"
+					}
+				`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Services []Service "hcl:\"service,block\" validate:\"dive\""
+						DB       DB        "hcl:\"db,block\""
+						Cache    *Cache    "hcl:\"cache,block\""
+					}
+					type Service struct {
+						ID   string "hcl:\"id,label\""
+						Name string "hcl:\"name,optional\""
+						Port int    "hcl:\"port\" validate:\"gt=0\""
+						TLS  *TLS   "hcl:\"tls,block\""
+					}
+					type TLS struct { Cert string "hcl:\"cert\"" }
+					type DB struct {
+						Kind string "hcl:\",label\""
+						Name string "hcl:\"name,label\""
+						Host string "hcl:\"host\""
+					}
+					type Cache struct { TTL string "hcl:\"ttl\"" }
+				`,
+			},
+		},
+		{
+			Name: "err_hcl_blocks",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": "service \"api\" {\n" +
+					"  port = \"http\"\n" +
+					"}\n" +
+					"service {\n" +
+					"  port = 80\n" +
+					"}\n",
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Services []Service "hcl:\"service,block\""
+						DB       DB        "hcl:\"db,block\""
+					}
+					type Service struct {
+						ID   string "hcl:\"id,label\""
+						Port int    "hcl:\"port\""
+					}
+					type DB struct { Host string "hcl:\"host\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				"line 1, column 1: Missing db block; A db block is required.",
+				"line 2, column 11: Unsuitable value type; " +
+					"Unsuitable value: a number is required",
+				"line 4, column 9: Missing id for service; " +
+					"All service blocks must have 1 labels (id).",
+			},
+		},
+		{
+			Name: "err_hcl_blocks_validate",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": `
+					service "api" { port = 8080 }
+					service "web" { port = 0 }
+				`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Services []Service "hcl:\"service,block\" validate:\"dive\""
+						Cache    *Cache    "hcl:\"cache,block\" valfile:\"required\""
+					}
+					type Service struct {
+						ID   string "hcl:\",label\""
+						Port int    "hcl:\"port\" validate:\"gt=0\""
+					}
+					type Cache struct { TTL string "hcl:\"ttl\"" }
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Cache: missing required field "cache"`,
+				"Key: 'Config.Services[1].Port' Error:" +
+					"Field validation for 'Port' failed on the 'gt' tag",
+			},
+		},
+		{
+			Name: "err_hcl_block_without_type",
+			Args: "-p $SETUP/tstcmd -t Config -f $SETUP/input.hcl",
+			Files: map[string]string{
+				"input.hcl": `server {}`,
+				"tstcmd/main.go": `package main
+					type Config struct {
+						Server Server "hcl:\",block\""
+						Name   string "hcl:\",optional\""
+					}
+					type Server struct {
+						Name string "hcl:\"name,label\""
+						Alt  string "hcl:\"name,optional\""
+						Host string "hcl:\"name\""
+					}
+				`,
+			},
+			ExpectErrs: []string{
+				`Config.Server: tag "hcl" has option ",block" but no block type`,
+				`Config.Name: tag "hcl" has options ",optional" but no name`,
+				`Server.Host: duplicate hcl tag "name" (also on Server.Alt)`,
+			},
+		},
 	} {
 		t.Run(td.Name, func(t *testing.T) {
 			td.validateName(t)
@@ -146,10 +3752,14 @@ func TestCLI(t *testing.T) {
 
 			// Replace variable $SETUP with the actual setup directory path
 			td.Args = strings.ReplaceAll(td.Args, "$SETUP", dir)
+			for i := range td.ExpectErrs {
+				td.ExpectErrs[i] = strings.ReplaceAll(td.ExpectErrs[i], "$SETUP", dir)
+			}
 
 			// Include the executable name as first argument
 			args := append([]string{"valfile"}, strings.Fields(td.Args)...)
 
+			stdin = strings.NewReader(td.Stdin)
 			errs := run(args, t.TempDir, func() []string { return td.EnvVars })
 			if td.ExpectErrs == nil {
 				require.Nil(t, errs, "unexpected errors: %v", errs)
@@ -166,6 +3776,7 @@ type Test struct {
 	Files      map[string]string // file name to contents mapping
 	ExpectErrs []string          // expected error messages
 	EnvVars    []string          // key-value pairs
+	Stdin      string            // contents of the standard input
 }
 
 func (td Test) validateName(t *testing.T) {
@@ -199,3 +3810,151 @@ func prepareTestSetup(t *testing.T, td Test) (setupDir string) {
 	}
 	return setupDir
 }
+
+// writeLargePackage writes a package of n struct types to a temporary
+// directory, spread over 10 files, where each type depends on the next.
+func writeLargePackage(tb testing.TB, n int) (dir string) {
+	dir = tb.TempDir()
+	files := make([]strings.Builder, 10)
+	for i := range files {
+		files[i].WriteString("package main\n")
+	}
+	for i := 0; i < n; i++ {
+		next := "string"
+		if i+1 < n {
+			next = fmt.Sprintf("T%d", i+1)
+		}
+		fmt.Fprintf(&files[i%len(files)], "type T%d struct {\n"+
+			"\tName string `json:\"name\"`\n"+
+			"\tNext *%s `json:\"next\"`\n"+
+			"\tList []%s `json:\"list\"`\n"+
+			"}\n", i, next, next)
+	}
+	for i := range files {
+		p := filepath.Join(dir, fmt.Sprintf("types%d.go", i))
+		require.NoError(tb, os.WriteFile(p, []byte(files[i].String()), 0o644))
+	}
+	return dir
+}
+
+func TestFindType(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg, err := parsePackage(fset, writeLargePackage(t, 50))
+	require.NoError(t, err)
+	for _, name := range []string{"T0", "T13", "T49"} {
+		s := findType(fset, pkg, name)
+		require.NotNil(t, s, name)
+		require.Equal(t, name, s.Name.Name)
+	}
+	require.Nil(t, findType(fset, pkg, "T50"))
+
+	types, errs := resolveTypes(fset, pkg, "T0")
+	require.Nil(t, errs)
+	require.Len(t, types.Names, 50)
+}
+
+func TestResolveTypesDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"c.go": "package main\ntype Config struct { B B; A A; C C }\n" +
+			"type C struct { X CMissing; Y Shared }",
+		"a.go": "package main\ntype A struct { X AMissing; Y Shared }",
+		"b.go": "package main\ntype B struct { X BMissing; Y []Shared }",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+	for i := 0; i < 20; i++ {
+		fset := token.NewFileSet()
+		pkg, err := parsePackage(fset, dir)
+		require.NoError(t, err)
+		_, errs := resolveTypes(fset, pkg, "Config")
+		require.Equal(t, []string{
+			"undefined type: BMissing",
+			"undefined type: Shared",
+			"undefined type: AMissing",
+			"undefined type: CMissing",
+		}, toStrings(errs))
+	}
+}
+
+func TestCheckMarshalingTagsTypeField(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config.go", `package main
+type Config struct {
+	Name   string `+"`json:\"name\"`"+`
+	Server struct {
+		Port int
+	} `+"`json:\"server\"`"+`
+}`, 0)
+	require.NoError(t, err)
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	errs := checkMarshalingTags(fset, spec, "json", "", true)
+	require.Len(t, errs, 1)
+	require.Equal(t, &Diagnostic{
+		File:    "config.go",
+		Line:    5,
+		Column:  3,
+		Code:    CodeTag,
+		Message: `Config.Server.Port: missing tag "json"`,
+		Type:    "Config",
+		Field:   "Server.Port",
+	}, errs[0])
+}
+
+func BenchmarkResolveTypes(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		dir := writeLargePackage(b, n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fset := token.NewFileSet()
+				pkg, err := parsePackage(fset, dir)
+				if err != nil {
+					b.Fatal(err)
+				}
+				types, errs := resolveTypes(fset, pkg, "T0")
+				if errs != nil {
+					b.Fatal(errs)
+				}
+				_ = mustRenderSrc(tmplJSON, TemplateData{
+					TypeDefinitions: types.Definitions,
+					RootTypeName:    types.Names[0],
+					Imports:         sortedKeys(types.Imports),
+					Tag:             "json",
+				})
+			}
+		})
+	}
+}
+
+func TestParseCLIParametersSilent(t *testing.T) {
+	for _, tt := range []struct {
+		args   []string
+		silent bool
+	}{
+		{[]string{"-p", "pkg", "-silent", "-t", "Config", "-f", "c.json"}, true},
+		{[]string{"--silent=true", "-t", "Config", "-f", "c.json"}, true},
+		{[]string{"-silent=1", "-t", "Config", "-f", "c.json"}, true},
+		{[]string{"-silent=T", "-t", "Config", "-f", "c.json"}, true},
+		{[]string{"-silent=TRUE", "-t", "Config", "-f", "c.json"}, true},
+		{[]string{"-p", "pkg", "-silent=false", "-t", "Config", "-f", "c.json"}, false},
+		{[]string{"-p", "pkg", "-t", "-silent", "-f", "c.json"}, false},
+	} {
+		p, err := parseCLIParameters(append([]string{"valfile"}, tt.args...))
+		require.NoError(t, err, tt.args)
+		require.Equal(t, tt.silent, p.Silent, tt.args)
+	}
+
+	// Errors don't reset it.
+	p, err := parseCLIParameters([]string{"valfile", "-silent", "-t", "Config"})
+	require.EqualError(t, err, "missing input file")
+	require.True(t, p.Silent)
+}
+
+func TestRunCLISilent(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	code := RunCLI([]string{"valfile", "-silent", "-t", "Config"})
+	require.Equal(t, ExitUsage, code)
+	// The output of other goroutines isn't affected.
+	require.Same(t, stdout, os.Stdout)
+	require.Same(t, stderr, os.Stderr)
+}
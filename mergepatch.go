@@ -0,0 +1,73 @@
+package valfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// readMergePatched returns the base file of p with the
+// JSON Merge Patch file of p applied.
+func readMergePatched(p Params) ([]byte, error) {
+	read := func(name string) ([]byte, error) {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkInputSize(p, fi.Size()); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(name)
+	}
+	base, err := read(p.MergeBase)
+	if err != nil {
+		return nil, fmt.Errorf("reading base: %w", err)
+	}
+	patch, err := read(p.MergePatch)
+	if err != nil {
+		return nil, fmt.Errorf("reading merge patch: %w", err)
+	}
+	return applyMergePatch(base, patch)
+}
+
+// applyMergePatch returns the JSON document base with the
+// JSON Merge Patch (RFC 7386) patch applied.
+func applyMergePatch(base, patch []byte) ([]byte, error) {
+	decode := func(b []byte) (v any, err error) {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		err = d.Decode(&v)
+		return v, err
+	}
+	target, err := decode(base)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base: %w", err)
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("parsing merge patch: %w", err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+// mergePatch applies patch to target. Members of objects set to null
+// in patch are removed, any value that isn't an object replaces target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
@@ -0,0 +1,490 @@
+package valfile
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats.
+const (
+	OutputText    = "text"
+	OutputConcise = "concise"
+	OutputJUnit   = "junit"
+	OutputTAP     = "tap"
+	OutputJSON    = "json"
+	OutputGitHub  = "github"
+)
+
+var outputFormats = []string{
+	OutputText, OutputConcise, OutputJUnit, OutputTAP, OutputJSON, OutputGitHub,
+}
+
+// Report is the outcome of a valfile invocation.
+type Report struct {
+	// Errs are errors that aren't specific to any input,
+	// such as an unreadable config file.
+	Errs []error
+
+	Results []Result
+}
+
+// Result is the outcome of validating a single input.
+type Result struct {
+	// Input is the input file path, or "env" for environment variables.
+	Input string
+
+	// Type is the type the input was validated against in the form
+	// "package-dir.TypeName", empty if validated against multiple types.
+	Type string
+
+	Errs []error
+}
+
+// Failed returns true if r contains any errors other than warnings.
+func (r Report) Failed() bool {
+	if (Result{Errs: r.Errs}).Failed() {
+		return true
+	}
+	for _, res := range r.Results {
+		if res.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns true if r contains any errors other than warnings.
+func (r Result) Failed() bool {
+	for _, err := range r.Errs {
+		if severityOf(err) == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns all errors and warnings of r. Errors of results are prefixed
+// with the input name when r contains more than one result.
+func (r Report) Errors() (errs []error) {
+	errs = append(errs, withoutInfo(r.Errs)...)
+	for _, res := range r.Results {
+		for _, err := range withoutInfo(res.Errs) {
+			if len(r.Results) > 1 {
+				err = fmt.Errorf("%s: %w", res.Input, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// writeReport writes r to w in the given output format.
+func writeReport(w io.Writer, format string, r Report) error {
+	switch format {
+	case OutputConcise:
+		return writeConcise(w, r)
+	case OutputJUnit:
+		return writeJUnit(w, r)
+	case OutputTAP:
+		return writeTAP(w, r)
+	case OutputJSON:
+		return writeJSON(w, r)
+	case OutputGitHub:
+		return writeGitHub(w, r)
+	}
+	for _, err := range r.Errors() {
+		line := err.Error()
+		if l := tagLocation(err); l != "" {
+			line = l + ": " + line
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagLocation returns the location of the field in the Go source
+// a tag diagnostic refers to in the form "file:line:col",
+// or an empty string if err isn't a tag diagnostic or has no location.
+func tagLocation(err error) string {
+	var d *Diagnostic
+	if !errors.As(err, &d) || d.Code != CodeTag || d.File == "" || d.Line < 1 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+}
+
+// writeConcise writes one line per error to w in the form
+// "file:line: [CODE] message", sorted by file and line.
+func writeConcise(w io.Writer, r Report) error {
+	var diags []Diagnostic
+	add := func(input string, errs []error) {
+		for _, err := range withoutInfo(errs) {
+			d := *asDiagnostic(err)
+			if d.File == "" {
+				d.File = input
+			}
+			diags = append(diags, d)
+		}
+	}
+	add("", r.Errs)
+	for _, res := range r.Results {
+		add(res.Input, res.Errs)
+	}
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		if c := cmp.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Line, b.Line)
+	})
+	for _, d := range diags {
+		var location string
+		switch {
+		case d.File != "" && d.Line > 0:
+			location = fmt.Sprintf("%s:%d: ", d.File, d.Line)
+		case d.File != "":
+			location = d.File + ": "
+		}
+		_, err := fmt.Fprintf(
+			w, "%s[%s] %s\n", location, d.Code, singleLine(d.Error()),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGitHub writes every error and warning of r to w as a GitHub Actions
+// workflow command, which annotates the file and line it refers to.
+// The file defaults to the input, diagnostics without a line annotate
+// the whole file and those without a file the workflow run.
+func writeGitHub(w io.Writer, r Report) error {
+	write := func(input string, errs []error) error {
+		for _, err := range withoutInfo(errs) {
+			d := asDiagnostic(err)
+			file := d.File
+			if file == "" && !slices.Contains([]string{"env", "set", "stdin"}, input) {
+				file = input
+			}
+			var props []string
+			if file != "" {
+				props = append(props, "file="+escapeGitHubProperty(file))
+				if d.Line > 0 {
+					props = append(props, fmt.Sprintf("line=%d", d.Line))
+				}
+				if d.Line > 0 && d.Column > 0 {
+					props = append(props, fmt.Sprintf("col=%d", d.Column))
+				}
+			}
+			props = append(props, "title="+escapeGitHubProperty("valfile "+d.Code))
+			command := "error"
+			if d.Severity == SeverityWarning {
+				command = "warning"
+			}
+			_, err := fmt.Fprintf(w, "::%s %s::%s\n",
+				command, strings.Join(props, ","), escapeGitHubData(d.Message))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write("", r.Errs); err != nil {
+		return err
+	}
+	for _, res := range r.Results {
+		if err := write(res.Input, res.Errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeGitHubData escapes s for the message of a GitHub Actions
+// workflow command, which may span multiple lines.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes s for a property value
+// of a GitHub Actions workflow command.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C",
+	).Replace(s)
+}
+
+// singleLine joins the lines of s with "; ",
+// or with a space if the preceding line ends with a colon.
+func singleLine(s string) string {
+	var b strings.Builder
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			if strings.HasSuffix(b.String(), ":") {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("; ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// Defaults report formats.
+const (
+	ReportDefaultsJSON = "json"
+)
+
+var reportDefaultsFormats = []string{ReportDefaultsJSON}
+
+// writeDefaultsReport writes the optional fields of every input of r
+// that fall back to their default values to w as JSON.
+func writeDefaultsReport(w io.Writer, r Report) error {
+	type input struct {
+		Input    string           `json:"input"`
+		Defaults []DefaultedField `json:"defaults"`
+	}
+	inputs := make([]input, len(r.Results))
+	for i, res := range r.Results {
+		inputs[i] = input{Input: res.Input, Defaults: []DefaultedField{}}
+		for _, err := range res.Errs {
+			if d := asDiagnostic(err); d.Defaulted != nil {
+				inputs[i].Defaults = append(inputs[i].Defaults, *d.Defaulted)
+			}
+		}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(struct {
+		Inputs []input `json:"inputs"`
+	}{Inputs: inputs})
+}
+
+// writeDefaultsReportFile writes the defaults report of r to the file
+// at path, or to w if path is empty.
+func writeDefaultsReportFile(w io.Writer, path string, r Report) error {
+	if path == "" {
+		return writeDefaultsReport(w, r)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writing defaults report: %w", err)
+	}
+	if err := writeDefaultsReport(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("writing defaults report: %w", err)
+	}
+	return f.Close()
+}
+
+// writeEffective writes the effective input of every valid input of r to w.
+// If r contains more than one result, each is preceded by a comment
+// with the input name.
+func writeEffective(w io.Writer, r Report) error {
+	for _, res := range r.Results {
+		if res.Failed() {
+			continue
+		}
+		for _, err := range res.Errs {
+			d := asDiagnostic(err)
+			if d.Code != CodeEffective {
+				continue
+			}
+			if len(r.Results) > 1 {
+				if _, err := fmt.Fprintf(w, "# %s\n", res.Input); err != nil {
+					return err
+				}
+			}
+			s := strings.TrimSuffix(d.Message, "\n") + "\n"
+			if _, err := io.WriteString(w, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes r to w as JUnit XML where each input is a test case.
+// Errors not specific to any input are reported as a failed "valfile" test case.
+func writeJUnit(w io.Writer, r Report) error {
+	s := junitTestSuite{Name: "valfile"}
+	addCase := func(name string, errs []error) {
+		errs = withoutInfo(errs)
+		c := junitTestCase{Name: name, ClassName: "valfile"}
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		switch {
+		case (Result{Errs: errs}).Failed():
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d error(s)", len(errs)),
+				Text:    strings.Join(msgs, "\n"),
+			}
+			s.Failures++
+		case len(errs) > 0:
+			c.SystemOut = strings.Join(msgs, "\n")
+		}
+		s.Tests++
+		s.Cases = append(s.Cases, c)
+	}
+	if len(r.Errs) > 0 {
+		addCase("valfile", r.Errs)
+	}
+	for _, res := range r.Results {
+		addCase(res.Input, res.Errs)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(junitTestSuites{Suites: []junitTestSuite{s}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tapDiagnostic is the YAML diagnostic block of a failed TAP test point.
+type tapDiagnostic struct {
+	Message  string   `yaml:"message"`
+	Severity string   `yaml:"severity"`
+	Errors   []string `yaml:"errors"`
+}
+
+// writeTAP writes r to w in the Test Anything Protocol version 13 where
+// each input is a test point. Errors of failed inputs are written as
+// a YAML diagnostic block, warnings of passed inputs as comments.
+// Errors not specific to any input are reported as a failed "valfile" test point.
+func writeTAP(w io.Writer, r Report) error {
+	results := r.Results
+	if len(r.Errs) > 0 {
+		results = append([]Result{{Input: "valfile", Errs: r.Errs}}, results...)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(results))
+	for i, res := range results {
+		errs := withoutInfo(res.Errs)
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		if !res.Failed() {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, res.Input)
+			for _, m := range msgs {
+				fmt.Fprintf(&b, "# %s\n", singleLine(m))
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, res.Input)
+		var y strings.Builder
+		e := yaml.NewEncoder(&y)
+		e.SetIndent(2)
+		err := e.Encode(tapDiagnostic{
+			Message:  fmt.Sprintf("%d error(s)", len(errs)),
+			Severity: "fail",
+			Errors:   msgs,
+		})
+		if err != nil {
+			return err
+		}
+		b.WriteString("  ---\n")
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(y.String(), "\n"), "\n") {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n  ...\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsonDiagnostic is an error or warning written by writeJSON.
+type jsonDiagnostic struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+// writeJSON writes all errors and warnings of r to w as a JSON array.
+// The file defaults to the input and the type to the name of the type
+// the input was validated against.
+func writeJSON(w io.Writer, r Report) error {
+	diags := []jsonDiagnostic{}
+	add := func(input, typ string, errs []error) {
+		// Only the name of the type in the form "package-dir.TypeName".
+		if i := strings.LastIndex(typ, "."); i >= 0 {
+			typ = typ[i+1:]
+		}
+		for _, err := range withoutInfo(errs) {
+			d := asDiagnostic(err)
+			j := jsonDiagnostic{
+				File:     d.File,
+				Line:     d.Line,
+				Column:   d.Column,
+				Type:     d.Type,
+				Field:    d.Field,
+				Severity: d.Severity,
+				Code:     d.Code,
+				Message:  d.Message,
+			}
+			if j.File == "" {
+				j.File = input
+			}
+			if j.Type == "" {
+				j.Type = typ
+			}
+			diags = append(diags, j)
+		}
+	}
+	add("", "", r.Errs)
+	for _, res := range r.Results {
+		add(res.Input, res.Type, res.Errs)
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(diags)
+}
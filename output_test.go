@@ -0,0 +1,276 @@
+package valfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReportJUnit(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputJUnit, Report{
+		Results: []Result{
+			{Input: "prod.json"},
+			{Input: "dev.json", Errs: []error{
+				errors.New(`Config.Foo: missing tag "json"`),
+				errors.New(`json: unknown field "bar"`),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="valfile" tests="2" failures="1">
+    <testcase name="prod.json" classname="valfile"></testcase>
+    <testcase name="dev.json" classname="valfile">
+      <failure message="2 error(s)">Config.Foo: missing tag &#34;json&#34;&#xA;json: unknown field &#34;bar&#34;</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, b.String())
+}
+
+func TestWriteReportText(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputText, Report{
+		Errs: []error{errors.New("unreadable config")},
+		Results: []Result{{Input: "dev.yaml", Errs: []error{
+			&Diagnostic{
+				File:    "config/config.go",
+				Line:    12,
+				Column:  2,
+				Code:    CodeTag,
+				Message: `Config.Foo: missing tag "yaml"`,
+			},
+			&Diagnostic{Code: CodeTag, Message: `Config.Bar: missing tag "yaml"`},
+			newInvalidInputDiagnostic("yaml: line 3: mapping values " +
+				"are not allowed in this context"),
+		}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `unreadable config
+config/config.go:12:2: Config.Foo: missing tag "yaml"
+Config.Bar: missing tag "yaml"
+yaml: line 3: mapping values are not allowed in this context
+`, b.String())
+}
+
+func TestWriteReportConcise(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputConcise, Report{
+		Errs: []error{errors.New("unreadable config")},
+		Results: []Result{
+			{Input: "prod.yaml", Errs: []error{
+				newInvalidInputDiagnostic("yaml: unmarshal errors:\n" +
+					"  line 7: field foo not found in type main.Config"),
+				newInvalidInputDiagnostic("yaml: line 3: mapping values " +
+					"are not allowed in this context"),
+			}},
+			{Input: "dev.yaml", Errs: []error{
+				&Diagnostic{
+					File:    "config/config.go",
+					Line:    12,
+					Code:    CodeTag,
+					Message: `Config.Foo: missing tag "yaml"`,
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `[ERROR] unreadable config
+config/config.go:12: [TAG] Config.Foo: missing tag "yaml"
+prod.yaml:3: [INVALID] yaml: line 3: mapping values are not allowed in this context
+prod.yaml:7: [INVALID] yaml: unmarshal errors: line 7: field foo not found in type main.Config
+`, b.String())
+}
+
+func TestWriteReportGitHub(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputGitHub, Report{
+		Errs: []error{errors.New("unreadable config")},
+		Results: []Result{
+			{Input: "prod.yaml", Errs: []error{
+				newInvalidInputDiagnostic("yaml: line 3: mapping values " +
+					"are not allowed in this context"),
+				&Diagnostic{
+					Severity: SeverityWarning,
+					Code:     CodeInvalid,
+					Message:  "Config.Timeout: zero value",
+				},
+				&Diagnostic{Severity: SeverityInfo, Code: CodeDefault},
+			}},
+			{Input: "dev.yaml", Errs: []error{
+				&Diagnostic{
+					File:    "config/config.go",
+					Line:    12,
+					Column:  2,
+					Code:    CodeTag,
+					Message: `Config.Foo: missing tag "yaml"`,
+				},
+			}},
+			{Input: "env", Errs: []error{
+				newInvalidInputDiagnostic("Config.Port: 100% invalid\nsee docs"),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `::error title=valfile ERROR::unreadable config
+::error file=prod.yaml,line=3,title=valfile INVALID::yaml: line 3: mapping values are not allowed in this context
+::warning file=prod.yaml,title=valfile INVALID::Config.Timeout: zero value
+::error file=config/config.go,line=12,col=2,title=valfile TAG::Config.Foo: missing tag "yaml"
+::error title=valfile INVALID::Config.Port: 100%25 invalid%0Asee docs
+`, b.String())
+
+	require.Equal(t, "C%3A/a%2Cb%25", escapeGitHubProperty("C:/a,b%"))
+}
+
+func TestWriteDefaultsReport(t *testing.T) {
+	var b bytes.Buffer
+	err := writeDefaultsReport(&b, Report{
+		Results: []Result{
+			{Input: "prod.yaml"},
+			{Input: "dev.yaml", Errs: parseProgramOutput([]byte(
+				StdoutErrPrefix + "Config.Port: missing required field \"port\"\n" +
+					StdoutDefaultPrefix + "Config.Host\thost\t\"\"\n",
+			))},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "inputs": [
+    {
+      "input": "prod.yaml",
+      "defaults": []
+    },
+    {
+      "input": "dev.yaml",
+      "defaults": [
+        {
+          "path": "Config.Host",
+          "key": "host",
+          "default": "\"\""
+        }
+      ]
+    }
+  ]
+}
+`, b.String())
+}
+
+func TestWriteDefaultsReportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	err := writeDefaultsReportFile(nil, path, Report{
+		Results: []Result{{Input: "prod.yaml"}},
+	})
+	require.NoError(t, err)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"inputs": [{"input": "prod.yaml", "defaults": []}]}`, string(b))
+
+	err = writeDefaultsReportFile(nil, filepath.Join(path, "x.json"), Report{})
+	require.ErrorContains(t, err, "writing defaults report: ")
+}
+
+func TestWriteReportTAP(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputTAP, Report{
+		Results: []Result{
+			{Input: "prod.json", Errs: []error{&Diagnostic{
+				Severity: SeverityWarning,
+				Message:  `Config.Port: "lport" is a deprecated alias of "port"`,
+			}}},
+			{Input: "dev.json", Errs: []error{
+				errors.New(`Config.Foo: missing tag "json"`),
+				errors.New("Key: 'Config.Port' Error:Field validation " +
+					"for 'Port' failed on the 'gt' tag"),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `TAP version 13
+1..2
+ok 1 - prod.json
+# warning: Config.Port: "lport" is a deprecated alias of "port"
+not ok 2 - dev.json
+  ---
+  message: 2 error(s)
+  severity: fail
+  errors:
+    - 'Config.Foo: missing tag "json"'
+    - 'Key: ''Config.Port'' Error:Field validation for ''Port'' failed on the ''gt'' tag'
+  ...
+`, b.String())
+}
+
+func TestWriteReportJSON(t *testing.T) {
+	var b bytes.Buffer
+	err := writeReport(&b, OutputJSON, Report{
+		Errs: []error{errors.New("unreadable config")},
+		Results: []Result{
+			{Input: "prod.yaml", Type: "./config.Config", Errs: []error{
+				newInvalidInputDiagnostic("yaml: unmarshal errors:\n" +
+					"  line 7: field foo not found in type main.Config"),
+			}},
+			{Input: "dev.yaml", Type: "./config.Config", Errs: []error{
+				&Diagnostic{
+					File:    "config/config.go",
+					Line:    12,
+					Column:  2,
+					Code:    CodeTag,
+					Message: `Config.Server.Foo: missing tag "yaml"`,
+					Type:    "Config",
+					Field:   "Server.Foo",
+				},
+				&Diagnostic{
+					Severity: SeverityWarning,
+					Code:     CodeUnused,
+					Message:  "Config.Bar: not set by any input",
+				},
+				&Diagnostic{Severity: SeverityInfo, Code: CodeEffective},
+			}},
+			{Input: "ok.yaml", Type: "./config.Config"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `[
+  {
+    "severity": "error",
+    "code": "ERROR",
+    "message": "unreadable config"
+  },
+  {
+    "file": "prod.yaml",
+    "line": 7,
+    "type": "Config",
+    "severity": "error",
+    "code": "INVALID",
+    "message": "yaml: unmarshal errors:\n  line 7: field foo not found in type main.Config"
+  },
+  {
+    "file": "config/config.go",
+    "line": 12,
+    "column": 2,
+    "type": "Config",
+    "field": "Server.Foo",
+    "severity": "error",
+    "code": "TAG",
+    "message": "Config.Server.Foo: missing tag \"yaml\""
+  },
+  {
+    "file": "dev.yaml",
+    "type": "Config",
+    "severity": "warning",
+    "code": "UNUSED",
+    "message": "Config.Bar: not set by any input"
+  }
+]
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, writeReport(&b, OutputJSON, Report{}))
+	require.Equal(t, "[]\n", b.String())
+}
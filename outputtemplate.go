@@ -0,0 +1,102 @@
+package valfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// OutputTemplateData is the data output templates passed to -output-template
+// are executed with. Diagnostics of severity info aren't included.
+type OutputTemplateData struct {
+	// Failed is true if any diagnostic is an error.
+	Failed bool
+
+	// Errors are the diagnostics that aren't specific to any input.
+	Errors []Diagnostic
+
+	Results []OutputTemplateResult
+}
+
+// OutputTemplateResult is the outcome of validating a single input.
+type OutputTemplateResult struct {
+	// Input is the input file path, or "env" for environment variables.
+	Input string
+
+	// Type is the type the input was validated against in the form
+	// "package-dir.TypeName", empty if validated against multiple types.
+	Type string
+
+	// Failed is true if any diagnostic of the input is an error.
+	Failed bool
+
+	// Diagnostics are the errors and warnings of the input.
+	// Unless set, File is the input file path.
+	Diagnostics []Diagnostic
+}
+
+// outputTemplateFuncs are the functions available to output templates
+// in addition to the text/template builtins.
+var outputTemplateFuncs = template.FuncMap{
+	// json returns v encoded as JSON.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// singleLine joins the lines of a multiline message.
+	"singleLine": singleLine,
+	// replace replaces all occurrences of old in s by new.
+	"replace": func(s, old, new string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// parseOutputTemplate parses the output template file at path.
+func parseOutputTemplate(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading output template: %w", err)
+	}
+	t, err := template.New(filepath.Base(path)).
+		Funcs(outputTemplateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing output template: %w", err)
+	}
+	return t, nil
+}
+
+// writeTemplate executes t with the OutputTemplateData of r and writes it to w.
+func writeTemplate(w io.Writer, t *template.Template, r Report) error {
+	diagnostics := func(input string, errs []error) []Diagnostic {
+		errs = withoutInfo(errs)
+		diags := make([]Diagnostic, len(errs))
+		for i, err := range errs {
+			diags[i] = *asDiagnostic(err)
+			if diags[i].File == "" {
+				diags[i].File = input
+			}
+		}
+		return diags
+	}
+	d := OutputTemplateData{
+		Failed:  r.Failed(),
+		Errors:  diagnostics("", r.Errs),
+		Results: make([]OutputTemplateResult, len(r.Results)),
+	}
+	for i, res := range r.Results {
+		d.Results[i] = OutputTemplateResult{
+			Input:       res.Input,
+			Type:        res.Type,
+			Failed:      res.Failed(),
+			Diagnostics: diagnostics(res.Input, res.Errs),
+		}
+	}
+	if err := t.Execute(w, d); err != nil {
+		return fmt.Errorf("executing output template: %w", err)
+	}
+	return nil
+}
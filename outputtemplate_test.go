@@ -0,0 +1,70 @@
+package valfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "teamcity.tmpl")
+	err := os.WriteFile(path, []byte(`{{- range .Results -}}
+##teamcity[testStarted name='{{.Input}}']
+{{- range .Diagnostics}}
+{{.Severity}} {{.File}}:{{.Line}} [{{.Code}}] {{replace (singleLine .Message) "'" "|'"}}
+{{- end}}
+##teamcity[testFinished name='{{.Input}}' failed='{{.Failed}}']
+{{end -}}
+errors: {{json .Errors}}
+`), 0o644)
+	require.NoError(t, err)
+
+	tmpl, err := parseOutputTemplate(path)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	err = writeTemplate(&b, tmpl, Report{
+		Results: []Result{
+			{Input: "prod.yaml", Type: "tstcmd.Config", Errs: []error{
+				&Diagnostic{
+					Severity: SeverityWarning,
+					Code:     CodeInvalid,
+					Message:  `Config.Port: "lport" is a deprecated alias of "port"`,
+				},
+				&Diagnostic{Severity: SeverityInfo, Code: CodeDefault},
+			}},
+			{Input: "dev.yaml", Type: "tstcmd.Config", Errs: []error{
+				newInvalidInputDiagnostic("yaml: unmarshal errors:\n" +
+					"  line 2: field host not found in type main.Config"),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `##teamcity[testStarted name='prod.yaml']
+warning prod.yaml:0 [INVALID] Config.Port: "lport" is a deprecated alias of "port"
+##teamcity[testFinished name='prod.yaml' failed='false']
+##teamcity[testStarted name='dev.yaml']
+error dev.yaml:2 [INVALID] yaml: unmarshal errors: line 2: field host not found in type main.Config
+##teamcity[testFinished name='dev.yaml' failed='true']
+errors: []
+`, b.String())
+
+	b.Reset()
+	err = writeTemplate(&b, tmpl, Report{Errs: []error{errors.New("it's broken")}})
+	require.NoError(t, err)
+	require.Equal(t, `errors: [{"Severity":"error","File":"","Line":0,"Column":0,`+
+		`"Code":"ERROR","Message":"it's broken","Type":"","Field":"",`+
+		`"Defaulted":null}]
+`, b.String())
+}
+
+func TestParseOutputTemplateErr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("{{.Results"), 0o644))
+	_, err := parseOutputTemplate(path)
+	require.ErrorContains(t, err, "parsing output template: template: broken.tmpl:1:")
+}
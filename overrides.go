@@ -0,0 +1,83 @@
+package valfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Override sets the value at Path of an input constructed on the fly.
+type Override struct {
+	Path  []string
+	Value string
+}
+
+// encodeOverrides constructs an input of the given format from overrides.
+// Values are interpreted as YAML scalars, such that "8080" is a number
+// and "true" is a boolean. Environment variables and dotenv files are flat,
+// their keys are the joined paths and their values are taken verbatim.
+// HCL, XML, INI and Dhall aren't supported.
+func encodeOverrides(format InputType, overrides []Override) ([]byte, error) {
+	switch format {
+	case InputTypeENV, InputTypeDOTENV, InputTypeENVRC:
+		var b strings.Builder
+		for _, o := range overrides {
+			key := strings.Join(o.Path, ".")
+			fmt.Fprintf(&b, "%s=%q\n", key, o.Value)
+		}
+		return []byte(b.String()), nil
+	case InputTypeJSON, InputTypeJSONNET, InputTypeCUE, InputTypeYAML, InputTypeTOML:
+	default:
+		return nil, fmt.Errorf("-set doesn't support format %s", format.MarshalingTag())
+	}
+
+	doc := map[string]any{}
+	for _, o := range overrides {
+		var v any
+		if err := yaml.Unmarshal([]byte(o.Value), &v); err != nil || v == nil {
+			v = o.Value
+		}
+		if err := setPath(doc, o.Path, v); err != nil {
+			return nil, err
+		}
+	}
+	switch format {
+	case InputTypeYAML:
+		return yaml.Marshal(doc)
+	case InputTypeTOML:
+		var b bytes.Buffer
+		if err := toml.NewEncoder(&b).Encode(doc); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	return json.Marshal(doc)
+}
+
+// setPath sets the value at path in m, creating intermediate maps.
+func setPath(m map[string]any, path []string, v any) error {
+	for i, k := range path[:len(path)-1] {
+		switch x := m[k].(type) {
+		case nil:
+			n := map[string]any{}
+			m[k] = n
+			m = n
+		case map[string]any:
+			m = x
+		default:
+			return fmt.Errorf("conflicting overrides: %s is not an object",
+				strings.Join(path[:i+1], "."))
+		}
+	}
+	last := path[len(path)-1]
+	if _, ok := m[last].(map[string]any); ok {
+		return fmt.Errorf("conflicting overrides: %s is an object",
+			strings.Join(path, "."))
+	}
+	m[last] = v
+	return nil
+}
@@ -0,0 +1,268 @@
+package valfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// schemaDraft is the JSON Schema dialect of schemas written by emitSchema.
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// emitSchema writes a JSON Schema of the type selected by p to w.
+// Every resolved type is a definition under "$defs" and the schema
+// refers to the root type. Property names are determined by the marshaling
+// tag of the input format selected by p, which defaults to JSON.
+func emitSchema(w io.Writer, p Params) []error {
+	inputType, err := selectedInputType(p)
+	if err != nil {
+		return []error{err}
+	}
+	if inputType == 0 {
+		inputType = InputTypeJSON
+	}
+	tag := inputType.MarshalingTag()
+	if tag != "json" && tag != "yaml" && tag != "toml" {
+		return withCode(CodeUsage, fmt.Errorf(
+			"-emit-schema isn't supported for %s input", tag,
+		))
+	}
+
+	_, types, errs := NewEngine(p, nil).resolve(inputType)
+	if errs != nil {
+		return errs
+	}
+	g := schemaGenerator{
+		tag:               tag,
+		requiredByDefault: p.FieldsRequiredByDefault,
+		specs:             make(map[string]*ast.TypeSpec, len(types.Names)),
+		defs:              make(map[string]any, len(types.Names)),
+	}
+	// The definitions are parsed again since they're the types
+	// as seen by the generated program, with other packages inlined.
+	fset := token.NewFileSet()
+	for i, name := range types.Names {
+		f, err := parser.ParseFile(fset, "", "package p\ntype "+types.Definitions[i], 0)
+		if err != nil {
+			return []error{fmt.Errorf("parsing type %s: %w", name, err)}
+		}
+		g.specs[name] = f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	}
+	for _, name := range types.Names {
+		g.define(name)
+	}
+
+	b, err := json.MarshalIndent(map[string]any{
+		"$schema": schemaDraft,
+		"$ref":    "#/$defs/" + types.Root,
+		"$defs":   g.defs,
+	}, "", "  ")
+	if err != nil {
+		return []error{err}
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// schemaGenerator generates the JSON Schema definitions of resolved types.
+type schemaGenerator struct {
+	tag               string
+	requiredByDefault bool
+	specs             map[string]*ast.TypeSpec
+	defs              map[string]any
+}
+
+// define adds the definition of the resolved type name to g.defs.
+func (g *schemaGenerator) define(name string) {
+	if _, ok := g.defs[name]; ok {
+		return
+	}
+	// Set before generating the definition to terminate on recursive types.
+	g.defs[name] = map[string]any{}
+	g.defs[name] = g.schema(g.specs[name].Type)
+}
+
+// schema returns the JSON Schema of the values of type expression t.
+func (g *schemaGenerator) schema(t ast.Expr) map[string]any {
+	switch t := t.(type) {
+	case *ast.ParenExpr:
+		return g.schema(t.X)
+	case *ast.StarExpr:
+		return g.schema(t.X)
+	case *ast.Ident:
+		if _, ok := g.specs[t.Name]; ok {
+			g.define(t.Name)
+			return map[string]any{"$ref": "#/$defs/" + t.Name}
+		}
+		return predeclaredSchema(t.Name)
+	case *ast.SelectorExpr:
+		return g.qualifiedSchema(t)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && t.Len == nil &&
+			(ident.Name == "byte" || ident.Name == "uint8") && g.tag == "json" {
+			// encoding/json encodes byte slices as base64 strings.
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		s := map[string]any{"type": "array", "items": g.schema(t.Elt)}
+		if lit, ok := t.Len.(*ast.BasicLit); ok {
+			if n, err := strconv.Atoi(lit.Value); err == nil {
+				s["minItems"], s["maxItems"] = n, n
+			}
+		}
+		return s
+	case *ast.MapType:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": g.schema(t.Value),
+		}
+	case *ast.StructType:
+		properties, required := map[string]any{}, []string{}
+		g.addFields(t, properties, &required)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	// Interfaces, functions and channels accept any value.
+	return map[string]any{}
+}
+
+// addFields adds the properties of the fields of struct type t to properties
+// and the names of the required ones to required. Fields of embedded structs
+// without a name in the marshaling tag are promoted, except for YAML where
+// only fields tagged inline are.
+func (g *schemaGenerator) addFields(
+	t *ast.StructType, properties map[string]any, required *[]string,
+) {
+	for _, f := range t.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			if s, err := strconv.Unquote(f.Tag.Value); err == nil {
+				tag = reflect.StructTag(s)
+			}
+		}
+		name, opts, _ := strings.Cut(tag.Get(g.tag), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		options := strings.Split(opts, ",")
+
+		names := make([]string, len(f.Names))
+		for i, n := range f.Names {
+			names[i] = n.Name
+		}
+		if f.Names == nil {
+			if st := g.embeddedStruct(f.Type); st != nil && name == "" &&
+				(g.tag != "yaml" || slices.Contains(options, "inline")) {
+				g.addFields(st, properties, required)
+				continue
+			}
+			names = []string{embeddedFieldName(f.Type)}
+		}
+
+		for _, n := range names {
+			if !ast.IsExported(n) {
+				continue
+			}
+			key := name
+			if key == "" {
+				key = n
+				if g.tag == "yaml" {
+					// yaml.v3 lowercases untagged field names.
+					key = strings.ToLower(n)
+				}
+			}
+			properties[key] = g.schema(f.Type)
+			if g.isRequired(tag, options) {
+				*required = append(*required, key)
+			}
+		}
+	}
+}
+
+// isRequired returns true if a field with the given tag
+// and marshaling tag options must be set.
+func (g *schemaGenerator) isRequired(tag reflect.StructTag, options []string) bool {
+	valfileOptions := strings.Split(tag.Get("valfile"), ",")
+	rules := strings.Split(tag.Get("validate"), ",")
+	if i := slices.Index(rules, "dive"); i != -1 {
+		// Rules after dive apply to the elements.
+		rules = rules[:i]
+	}
+	switch {
+	case slices.Contains(valfileOptions, "required"),
+		slices.Contains(rules, "required"):
+		return true
+	}
+	return g.requiredByDefault &&
+		!slices.Contains(valfileOptions, "optional") &&
+		!slices.Contains(options, "omitempty")
+}
+
+// embeddedStruct returns the struct type of embedded field type t,
+// or nil if t isn't a resolved struct type.
+func (g *schemaGenerator) embeddedStruct(t ast.Expr) *ast.StructType {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	ident, ok := t.(*ast.Ident)
+	if !ok || g.specs[ident.Name] == nil {
+		return nil
+	}
+	st, _ := g.specs[ident.Name].Type.(*ast.StructType)
+	return st
+}
+
+// predeclaredSchema returns the JSON Schema of the predeclared type name.
+func predeclaredSchema(name string) map[string]any {
+	switch name {
+	case "string":
+		return map[string]any{"type": "string"}
+	case "bool":
+		return map[string]any{"type": "boolean"}
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"byte", "rune":
+		s := map[string]any{"type": "integer"}
+		if strings.HasPrefix(name, "u") || name == "byte" {
+			s["minimum"] = 0
+		}
+		return s
+	case "float32", "float64":
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// qualifiedSchema returns the JSON Schema of the standard library type t.
+// Types the schema of which depends on their unmarshaling methods
+// accept any value.
+func (g *schemaGenerator) qualifiedSchema(t *ast.SelectorExpr) map[string]any {
+	pkg, ok := t.X.(*ast.Ident)
+	if !ok {
+		return map[string]any{}
+	}
+	switch pkg.Name + "." + t.Sel.Name {
+	case "time.Time":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "time.Duration":
+		if g.tag == "json" {
+			return map[string]any{"type": "integer"}
+		}
+		return map[string]any{"type": "string"}
+	case "net.IP", "netip.Addr":
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
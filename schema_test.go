@@ -0,0 +1,106 @@
+package valfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitSchema(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import "time"
+
+type Config struct {
+	Base
+	Name    string            "json:\"name\" yaml:\"name\" valfile:\"required\""
+	Port    *uint16           "json:\"port,omitempty\" yaml:\"port\""
+	Tags    []string          "json:\"tags\" validate:\"dive,required\""
+	Limits  map[string]Limit  "json:\"limits\" validate:\"required\""
+	Started time.Time         "json:\"started\""
+	Ignored bool              "json:\"-\" yaml:\"-\""
+	next    *Config
+}
+
+type Base struct {
+	ID int "json:\"id\" yaml:\"id\""
+}
+
+type Limit struct {
+	Max  float64 "json:\"max\""
+	Next *Limit  "json:\"next\""
+}
+`), 0o644))
+
+	var b bytes.Buffer
+	errs := emitSchema(&b, Params{PackageDir: dir, TypeName: "Config"})
+	require.Nil(t, errs)
+	require.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref": "#/$defs/Config",
+		"$defs": {
+			"Config": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "integer"},
+					"name": {"type": "string"},
+					"port": {"type": "integer", "minimum": 0},
+					"tags": {"type": "array", "items": {"type": "string"}},
+					"limits": {
+						"type": "object",
+						"additionalProperties": {"$ref": "#/$defs/Limit"}
+					},
+					"started": {"type": "string", "format": "date-time"}
+				},
+				"required": ["name", "limits"],
+				"additionalProperties": false
+			},
+			"Base": {
+				"type": "object",
+				"properties": {"id": {"type": "integer"}},
+				"required": [],
+				"additionalProperties": false
+			},
+			"Limit": {
+				"type": "object",
+				"properties": {
+					"max": {"type": "number"},
+					"next": {"$ref": "#/$defs/Limit"}
+				},
+				"required": [],
+				"additionalProperties": false
+			}
+		}
+	}`, b.String())
+
+	// YAML doesn't promote the fields of embedded structs unless inline
+	// and all fields not tagged omitempty are required by default.
+	b.Reset()
+	errs = emitSchema(&b, Params{
+		PackageDir:              dir,
+		TypeName:                "Base",
+		InputFile:               "config.yaml",
+		FieldsRequiredByDefault: true,
+	})
+	require.Nil(t, errs)
+	require.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref": "#/$defs/Base",
+		"$defs": {
+			"Base": {
+				"type": "object",
+				"properties": {"id": {"type": "integer"}},
+				"required": ["id"],
+				"additionalProperties": false
+			}
+		}
+	}`, b.String())
+
+	b.Reset()
+	errs = emitSchema(&b, Params{PackageDir: dir, TypeName: "Config", InputEnv: true})
+	require.Equal(t, []string{"-emit-schema isn't supported for env input"}, toStrings(errs))
+}
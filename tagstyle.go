@@ -0,0 +1,73 @@
+package valfile
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tag styles.
+const (
+	TagStyleSnake = "snake"
+	TagStyleCamel = "camel"
+	TagStyleKebab = "kebab"
+)
+
+var tagStyles = []string{TagStyleSnake, TagStyleCamel, TagStyleKebab}
+
+// applyTagStyle returns the tag name expected for the Go field name
+// in the given style, for example "max_conns" for "MaxConns" in snake case.
+// Names of environment variables are upper case.
+func applyTagStyle(style, fieldName, tag string) string {
+	words := splitWords(fieldName)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if style == TagStyleCamel && i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[i] = w
+	}
+	var name string
+	switch style {
+	case TagStyleSnake:
+		name = strings.Join(words, "_")
+	case TagStyleCamel:
+		name = strings.Join(words, "")
+	case TagStyleKebab:
+		name = strings.Join(words, "-")
+	}
+	if tag == "env" {
+		name = strings.ToUpper(name)
+	}
+	return name
+}
+
+// splitWords splits the Go identifier s into words. Acronyms are
+// a single word and digits belong to the preceding word,
+// for example "HTTPServer2Addr" is split into "HTTP", "Server2" and "Addr".
+func splitWords(s string) (words []string) {
+	r := []rune(s)
+	start := 0
+	for i := 1; i < len(r); i++ {
+		if r[i] == '_' {
+			if i > start {
+				words = append(words, string(r[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if !unicode.IsUpper(r[i]) || i == start {
+			continue
+		}
+		prev := r[i-1]
+		nextIsLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(unicode.IsUpper(prev) && nextIsLower) {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	if start < len(r) {
+		words = append(words, string(r[start:]))
+	}
+	return words
+}
@@ -0,0 +1,25 @@
+package valfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTagStyle(t *testing.T) {
+	for _, td := range []struct {
+		style, fieldName, tag string
+		expect                string
+	}{
+		{TagStyleSnake, "MaxConns", "json", "max_conns"},
+		{TagStyleSnake, "HTTPServer2Addr", "yaml", "http_server2_addr"},
+		{TagStyleSnake, "UserID", "env", "USER_ID"},
+		{TagStyleCamel, "UserID", "json", "userId"},
+		{TagStyleCamel, "Port", "json", "port"},
+		{TagStyleKebab, "TLSCertFile", "yaml", "tls-cert-file"},
+		{TagStyleKebab, "Legacy_Name", "toml", "legacy-name"},
+	} {
+		require.Equal(t, td.expect,
+			applyTagStyle(td.style, td.fieldName, td.tag), td.fieldName)
+	}
+}
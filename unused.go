@@ -0,0 +1,65 @@
+package valfile
+
+import (
+	"slices"
+	"strings"
+)
+
+// findUnusedFields returns the paths of the fields that none of the results
+// of their type set, according to the optional fields reported as defaulted.
+// Fields of elements of slices and maps aren't considered. A field is omitted
+// if the struct containing it is unused itself.
+func findUnusedFields(results []Result) (unused []string) {
+	// unset maps types to the paths of unset fields of every result.
+	unset := map[string][][]string{}
+	for _, res := range results {
+		if res.Type == "" {
+			continue
+		}
+		var paths []string
+		for _, err := range res.Errs {
+			d := asDiagnostic(err).Defaulted
+			if d != nil && !strings.Contains(d.Path, "[") {
+				paths = append(paths, d.Path)
+			}
+		}
+		unset[res.Type] = append(unset[res.Type], paths)
+	}
+
+	// isUnset returns true if path or a struct containing it is in paths.
+	isUnset := func(paths []string, path string) bool {
+		for _, p := range paths {
+			if p == path || strings.HasPrefix(path, p+".") {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range sortedKeys(unset) {
+		var candidates []string
+		for _, paths := range unset[t] {
+			candidates = append(candidates, paths...)
+		}
+		slices.Sort(candidates)
+		candidates = slices.Compact(candidates)
+
+		var unusedOfType []string
+		for _, c := range candidates {
+			if isUnset(unusedOfType, c) {
+				continue
+			}
+			unusedByAll := true
+			for _, paths := range unset[t] {
+				if !isUnset(paths, c) {
+					unusedByAll = false
+					break
+				}
+			}
+			if unusedByAll {
+				unusedOfType = append(unusedOfType, c)
+			}
+		}
+		unused = append(unused, unusedOfType...)
+	}
+	return unused
+}
@@ -0,0 +1,34 @@
+package valfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUnusedFields(t *testing.T) {
+	defaulted := func(paths ...string) (errs []error) {
+		for _, p := range paths {
+			errs = append(errs, &Diagnostic{
+				Severity:  SeverityInfo,
+				Code:      CodeDefault,
+				Defaulted: &DefaultedField{Path: p},
+			})
+		}
+		return errs
+	}
+	require.Equal(t, []string{
+		"Config.DB.Pool",
+		"Config.Legacy",
+		"Secrets.Old",
+	}, findUnusedFields([]Result{
+		{Type: "pkg.Config", Errs: defaulted(
+			"Config.DB", "Config.Legacy", "Config.Debug", "Config.Servers[0].Port",
+		)},
+		{Type: "pkg.Config", Errs: defaulted(
+			"Config.DB.Pool", "Config.Legacy", "Config.Servers[0].Port",
+		)},
+		{Type: "pkg.Secrets", Errs: defaulted("Secrets.Old")},
+		{Type: "", Errs: defaulted("Other.Field")},
+	}))
+}
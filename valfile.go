@@ -0,0 +1,38 @@
+// Package valfile validates config files and environment variables
+// against Go struct types by generating, compiling and running a program
+// decoding the input into the type. Command valfile in cmd/valfile
+// is the command line interface.
+package valfile
+
+import "os"
+
+// Options are the options of Validate.
+type Options struct {
+	// Params select the inputs and types like the command line parameters.
+	// InputFile is the input file, or the first of InputFiles if there
+	// are several, and TypeName is the first of AnyTypes if there are any.
+	Params
+
+	// MakeTmpDir returns the directory temporary directories are created in.
+	// Defaults to os.TempDir.
+	MakeTmpDir func() string
+
+	// Environ returns the environment variables validated if InputEnv is set
+	// in the form "key=value". Defaults to os.Environ.
+	Environ func() []string
+}
+
+// Validate validates the inputs selected by opts and returns all errors
+// and warnings, nil if all inputs are valid. Problems with inputs are
+// of type *Diagnostic, wrapped with the name of the input if there
+// are several, see Report.Errors.
+func Validate(opts Options) []error {
+	makeTmpDir, environ := opts.MakeTmpDir, opts.Environ
+	if makeTmpDir == nil {
+		makeTmpDir = os.TempDir
+	}
+	if environ == nil {
+		environ = os.Environ
+	}
+	return execute(opts.Params, makeTmpDir, environ).Errors()
+}
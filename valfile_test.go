@@ -0,0 +1,52 @@
+package valfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/valfile"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, "config")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "config.go"), []byte(`
+		package config
+		type Config struct { Port int "json:\"port\" validate:\"gt=0\"" }
+	`), 0o644))
+	input := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"port":0}`), 0o644))
+
+	errs := valfile.Validate(valfile.Options{
+		Params: valfile.Params{
+			PackageDir: pkgDir,
+			TypeName:   "Config",
+			InputFile:  input,
+		},
+		MakeTmpDir: t.TempDir,
+	})
+	require.Len(t, errs, 1)
+	var d *valfile.Diagnostic
+	require.True(t, errors.As(errs[0], &d))
+	require.Equal(t, valfile.CodeInvalid, d.Code)
+	require.Equal(t,
+		"Key: 'Config.Port' Error:Field validation for 'Port' failed on the 'gt' tag",
+		d.Message,
+	)
+
+	require.NoError(t, os.WriteFile(input, []byte(`{"port":8080}`), 0o644))
+	errs = valfile.Validate(valfile.Options{
+		Params: valfile.Params{
+			PackageDir: pkgDir,
+			TypeName:   "Config",
+			InputFile:  input,
+		},
+		MakeTmpDir: t.TempDir,
+	})
+	require.Nil(t, errs)
+}
@@ -0,0 +1,143 @@
+package valfile
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/structtag"
+)
+
+// checkValidateTags checks the syntax of the validate tags of the fields
+// of struct type t, including the fields of anonymous structs nested
+// in fields. Values are validated against the tags by the generated program,
+// which only reports malformed tags of fields it encounters.
+// If requireTag is true, exported fields other than embedded ones lacking
+// a validate tag are reported too, which validate:"-" opts out of.
+func checkValidateTags(
+	fset *token.FileSet, t *ast.TypeSpec, requireTag bool,
+) (errs []error) {
+	s, ok := t.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	var checkFields func(path string, s *ast.StructType)
+	checkFields = func(path string, s *ast.StructType) {
+		for _, f := range s.Fields.List {
+			fieldName := embeddedFieldName(f.Type)
+			if len(f.Names) > 0 {
+				fieldName = f.Names[0].Name
+			}
+			fieldPath := path + "." + fieldName
+			addErrf := func(msg string, v ...any) {
+				pos := fset.Position(f.Pos())
+				errs = append(errs, &Diagnostic{
+					File:    pos.Filename,
+					Line:    pos.Line,
+					Column:  pos.Column,
+					Code:    CodeTag,
+					Message: fmt.Sprintf("%s: %s", fieldPath, fmt.Sprintf(msg, v...)),
+					Type:    t.Name.Name,
+					Field:   strings.TrimPrefix(fieldPath, t.Name.Name+"."),
+				})
+			}
+			if n := anonymousStruct(f.Type); n != nil {
+				checkFields(fieldPath, n)
+			}
+			tag, ok := validateTag(f)
+			if !ok {
+				if requireTag && len(f.Names) > 0 && ast.IsExported(fieldName) {
+					addErrf(`missing tag "validate"`)
+				}
+				continue
+			}
+			if err := checkValidateTagSyntax(tag); err != nil {
+				addErrf("invalid validate tag %q: %v", tag, err)
+			}
+		}
+	}
+	checkFields(t.Name.Name, s)
+	return errs
+}
+
+// validateTag returns the value of the validate tag of f
+// and false if f has none.
+func validateTag(f *ast.Field) (string, bool) {
+	if f.Tag == nil {
+		return "", false
+	}
+	tagContent, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	tags, err := structtag.Parse(tagContent)
+	if err != nil {
+		// Reported by the marshaling tag check.
+		return "", false
+	}
+	tag, err := tags.Get("validate")
+	if err != nil {
+		return "", false
+	}
+	return tag.Value(), true
+}
+
+// checkValidateTagSyntax checks the syntax of the value of a validate tag
+// as parsed by github.com/go-playground/validator, which is a comma-separated
+// list of rules, each of which may be a |-separated list of alternatives
+// named like identifiers and optionally followed by = and a parameter.
+func checkValidateTagSyntax(tag string) error {
+	if tag == "" {
+		return errors.New("empty tag")
+	}
+	if tag == "-" {
+		return nil
+	}
+	var dive, keys bool
+	for _, rule := range strings.Split(tag, ",") {
+		switch rule {
+		case "dive":
+			dive = true
+			continue
+		case "keys":
+			if !dive {
+				return errors.New("keys must follow dive")
+			}
+			keys = true
+			continue
+		case "endkeys":
+			if !keys {
+				return errors.New("endkeys without keys")
+			}
+			keys = false
+			continue
+		}
+		for _, alt := range strings.Split(rule, "|") {
+			name, _, _ := strings.Cut(alt, "=")
+			if name == "" {
+				return errors.New("empty rule")
+			}
+			if !isRuleName(name) {
+				return fmt.Errorf("invalid rule name %q", name)
+			}
+		}
+	}
+	if keys {
+		return errors.New("keys without endkeys")
+	}
+	return nil
+}
+
+// isRuleName returns true if name is a valid name of a validation rule.
+func isRuleName(name string) bool {
+	for _, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') &&
+			(r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
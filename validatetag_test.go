@@ -0,0 +1,40 @@
+package valfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckValidateTagSyntax(t *testing.T) {
+	for _, td := range []struct {
+		tag    string
+		expect string
+	}{
+		{tag: "-"},
+		{tag: "required"},
+		{tag: "min=1,max=65535"},
+		{tag: "omitempty,oneof=a b c"},
+		{tag: "required_if=Mode tls,file"},
+		{tag: "hostname|ip"},
+		{tag: "dive,keys,alpha,endkeys,required"},
+		{tag: "", expect: "empty tag"},
+		{tag: "min=1,,max=2", expect: "empty rule"},
+		{tag: "required,", expect: "empty rule"},
+		{tag: "ip|", expect: "empty rule"},
+		{tag: "=1", expect: "empty rule"},
+		{tag: "min 1", expect: `invalid rule name "min 1"`},
+		{tag: "keys,alpha,endkeys", expect: "keys must follow dive"},
+		{tag: "endkeys", expect: "endkeys without keys"},
+		{tag: "dive,keys,alpha", expect: "keys without endkeys"},
+	} {
+		t.Run(td.tag, func(t *testing.T) {
+			err := checkValidateTagSyntax(td.tag)
+			if td.expect == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, td.expect)
+		})
+	}
+}
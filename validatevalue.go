@@ -0,0 +1,141 @@
+package valfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateValue checks that the value v points to, or v itself, is correctly
+// tagged for the given format ("json", "yaml" or "toml") by marshaling it and
+// strictly decoding it back into a new value of the same type.
+// Fields lacking the marshaling tag of the format and fields
+// whose values don't survive the round-trip are reported.
+func ValidateValue(v any, format string) (errs []error) {
+	inputType, err := parseFormat(format)
+	if err != nil {
+		return []error{err}
+	}
+	switch inputType {
+	case InputTypeJSON, InputTypeYAML, InputTypeTOML:
+	default:
+		// Checked before the tags, which would be reported
+		// although the value can't be round-tripped anyway.
+		return []error{fmt.Errorf("unsupported format: %q", format)}
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return []error{fmt.Errorf("expected a struct, received: %T", v)}
+	}
+	tag := inputType.MarshalingTag()
+	t := rv.Type()
+
+	checkValueTags(t, tag, map[reflect.Type]bool{}, &errs)
+	if errs != nil {
+		return errs
+	}
+
+	decoded := reflect.New(t)
+	if err := roundTrip(inputType, rv.Interface(), decoded.Interface()); err != nil {
+		return []error{err}
+	}
+	diffValues(rv, decoded.Elem(), t.Name(), format, &errs)
+	return errs
+}
+
+// roundTrip marshals v in the format of inputType and strictly decodes
+// the result into the value dst points to.
+func roundTrip(inputType InputType, v, dst any) error {
+	switch inputType {
+	case InputTypeJSON:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshaling: %w", err)
+		}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		return d.Decode(dst)
+	case InputTypeYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshaling: %w", err)
+		}
+		d := yaml.NewDecoder(bytes.NewReader(b))
+		d.KnownFields(true)
+		return d.Decode(dst)
+	case InputTypeTOML:
+		var b bytes.Buffer
+		if err := toml.NewEncoder(&b).Encode(v); err != nil {
+			return fmt.Errorf("marshaling: %w", err)
+		}
+		md, err := toml.NewDecoder(&b).Decode(dst)
+		if err != nil {
+			return err
+		}
+		if u := md.Undecoded(); len(u) > 0 {
+			return fmt.Errorf("undecoded keys: %v", u)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported format: %q", inputType.MarshalingTag())
+}
+
+// checkValueTags reports all exported fields of struct type t and the
+// struct types it depends on that lack tag. visited prevents infinite
+// recursion on recursive types.
+func checkValueTags(t reflect.Type, tag string, visited map[reflect.Type]bool, errs *[]error) {
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t.Kind() != reflect.Struct || visited[t] || isStdType(t) {
+		return
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if _, ok := f.Tag.Lookup(tag); !ok && !(f.Anonymous && promotesEmbedded(tag)) {
+			*errs = append(*errs, fmt.Errorf("%s.%s: missing tag %q", t.Name(), f.Name, tag))
+		}
+		checkValueTags(f.Type, tag, visited, errs)
+	}
+}
+
+// isStdType returns true if t is declared by a standard library package.
+func isStdType(t reflect.Type) bool {
+	p := t.PkgPath()
+	return p != "" && p != "main" && isStdPackage(p)
+}
+
+// diffValues reports all fields of a that differ from b.
+// Fields of structs declared outside the standard library
+// are compared individually.
+func diffValues(a, b reflect.Value, path, format string, errs *[]error) {
+	if reflect.DeepEqual(a.Interface(), b.Interface()) {
+		return
+	}
+	if a.Kind() == reflect.Struct && !isStdType(a.Type()) {
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				diffValues(a.Field(i), b.Field(i), path+"."+t.Field(i).Name, format, errs)
+			}
+		}
+		return
+	}
+	*errs = append(*errs, fmt.Errorf(
+		"%s: value %v doesn't survive a %s round-trip, decoded: %v",
+		path, a.Interface(), format, b.Interface(),
+	))
+}
@@ -0,0 +1,51 @@
+package valfile
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValue(t *testing.T) {
+	type Server struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Config struct {
+		Servers []Server `json:"servers" yaml:"servers" toml:"servers"`
+		Secret  string   `json:"-" yaml:"secret" toml:"secret"`
+		Debug   bool     `yaml:"debug" toml:"debug"`
+	}
+	v := Config{
+		Servers: []Server{{Host: "a", Port: 80}},
+		Secret:  "s",
+		Debug:   true,
+	}
+
+	require.Nil(t, ValidateValue(&v, "yaml"))
+	require.Nil(t, ValidateValue(v, "toml"))
+	require.Equal(t, []string{
+		`Config.Debug: missing tag "json"`,
+	}, toStrings(ValidateValue(v, "json")))
+
+	type Lossy struct {
+		Name   string `json:"name"`
+		Secret string `json:"-"`
+	}
+	require.Equal(t, []string{
+		"Lossy.Secret: value s doesn't survive a json round-trip, decoded: ",
+	}, toStrings(ValidateValue(Lossy{Name: "n", Secret: "s"}, "json")))
+
+	require.Equal(t, []string{
+		`unsupported format: "xml"`,
+	}, toStrings(ValidateValue(v, "xml")))
+	for _, format := range []string{"ini", "cue", "dhall", "hcl", "csv"} {
+		require.Equal(t, []string{
+			fmt.Sprintf("unsupported format: %q", format),
+		}, toStrings(ValidateValue(v, format)))
+	}
+	require.Equal(t, []string{
+		"expected a struct, received: int",
+	}, toStrings(ValidateValue(42, "json")))
+}
@@ -0,0 +1,142 @@
+package valfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long changes are collected before validating again,
+// such that saving several files at once triggers a single run.
+var watchDelay = 100 * time.Millisecond
+
+// clearScreen moves the cursor to the top left corner of the terminal
+// and clears it, removing the output of the previous run.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watch calls validate and calls it again whenever a file watched
+// according to watchTargets changes, until ctx is canceled.
+// Every run is preceded by clearing the screen and a timestamp.
+func watch(ctx context.Context, w io.Writer, p Params, validate func()) error {
+	dirs, patterns, err := watchTargets(p)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watching: %w", err)
+	}
+	defer watcher.Close()
+	for _, d := range dirs {
+		if err := watcher.Add(d); err != nil {
+			return fmt.Errorf("watching %s: %w", d, err)
+		}
+	}
+
+	rerun := func() {
+		fmt.Fprint(w, clearScreen)
+		fmt.Fprintf(w, "--- %s ---\n", time.Now().Format(time.DateTime))
+		validate()
+	}
+	rerun()
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("watching: %w", err)
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if e.Op == fsnotify.Chmod || !matchesAny(patterns, e.Name) {
+				continue
+			}
+			delay = time.After(watchDelay)
+		case <-delay:
+			delay = nil
+			rerun()
+		}
+	}
+}
+
+// watchTargets returns the directories to watch for changes of the inputs
+// and Go packages selected by p, and the patterns of the relevant files
+// in them. Directories are watched instead of files, since editors
+// commonly save files by replacing them.
+func watchTargets(p Params) (dirs, patterns []string, err error) {
+	dirSet := map[string]bool{}
+	addDir := func(dir, pattern string) {
+		dir = filepath.Clean(dir)
+		dirSet[dir] = true
+		patterns = append(patterns, filepath.Join(dir, pattern))
+	}
+	addFile := func(path string) {
+		if isURL(path) {
+			return
+		}
+		addDir(filepath.Dir(path), filepath.Base(path))
+	}
+
+	addDir(p.PackageDir, "*.go")
+	for _, f := range []string{p.ConfigFile, p.Archive, p.MergePatch, p.MergeBase} {
+		if f != "" {
+			addFile(f)
+		}
+	}
+	switch {
+	case p.InputFiles != nil:
+		for _, f := range p.InputFiles {
+			addFile(f)
+		}
+	case p.InputFile != "" && p.Archive == "":
+		addFile(p.InputFile)
+	}
+	if p.InputDir != "" {
+		err := filepath.WalkDir(p.InputDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				addDir(path, "*")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading input directory: %w", err)
+		}
+	}
+	if p.ConfigFile != "" {
+		c, err := loadConfig(p.ConfigFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, t := range c.Targets {
+			addDir(t.Package, "*.go")
+			for _, f := range t.Files {
+				addFile(f)
+			}
+		}
+	}
+	return sortedKeys(dirSet), patterns, nil
+}
+
+// matchesAny returns true if path matches any of the patterns.
+func matchesAny(patterns []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, path); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package valfile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchTargets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "configs", "dev"), 0o755))
+
+	dirs, patterns, err := watchTargets(Params{
+		PackageDir: filepath.Join(dir, "pkg"),
+		InputFiles: []string{
+			filepath.Join(dir, "a.json"), filepath.Join(dir, "b", "b.yaml"),
+		},
+		InputDir: filepath.Join(dir, "configs"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		dir,
+		filepath.Join(dir, "b"),
+		filepath.Join(dir, "configs"),
+		filepath.Join(dir, "configs", "dev"),
+		filepath.Join(dir, "pkg"),
+	}, dirs)
+
+	for _, path := range []string{
+		"pkg/config.go", "a.json", "b/b.yaml", "configs/x.toml", "configs/dev/y.json",
+	} {
+		require.True(t, matchesAny(patterns, filepath.Join(dir, path)), path)
+	}
+	for _, path := range []string{
+		"pkg/README.md", "c.json", "b/a.json", "pkg/sub/config.go",
+	} {
+		require.False(t, matchesAny(patterns, filepath.Join(dir, path)), path)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { watchDelay = d }(watchDelay)
+	watchDelay = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{}`), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 8)
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, &out, Params{
+			PackageDir: dir,
+			InputFile:  input,
+		}, func() { runs <- struct{}{} })
+	}()
+
+	waitRun := func() {
+		t.Helper()
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a run")
+		}
+	}
+	waitRun()
+	// Unrelated files don't trigger a run.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644))
+	require.NoError(t, os.WriteFile(input, []byte(`{"a":1}`), 0o644))
+	waitRun()
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Len(t, runs, 0)
+	require.Equal(t, 2, strings.Count(out.String(), clearScreen))
+	require.Regexp(t, `--- \d{4}-\d\d-\d\d \d\d:\d\d:\d\d ---\n`, out.String())
+}